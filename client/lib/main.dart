@@ -181,6 +181,12 @@ class _TablePageState extends State<TablePage> {
   List<String> names = [];
   List<int> counts = [];
   List<int> stayed = [];
+  List<bool> ready = [];
+  int? host;
+
+  // Proves the seat is ours to the server, so we can take it back after a
+  // reconnect even without an account
+  String? seatToken;
 
   int talon = 0;
   int swamp = 0;
@@ -224,9 +230,13 @@ class _TablePageState extends State<TablePage> {
               counts = ((m['m']['counts'] as List?) ?? const []).map((e) => (e as num).toInt()).toList();
 
               stayed = ((m['m']['stayed'] as List?) ?? const []).map((e) => (e as num).toInt()).toList();
+              ready = ((m['m']['ready'] as List?) ?? const []).map((e) => e == true).toList();
+              host = (m['m']['host'] as num?)?.toInt();
               talon = ((m['m']['talon'] as num?) ?? 0).toInt();
               swamp = ((m['m']['swamp'] as num?) ?? 0).toInt();
               exchangeMax = ((m['m']['exchangeMax'] as num?) ?? 3).toInt();
+              final tok = (m['m']['token'] ?? '').toString();
+              if (tok.isNotEmpty) seatToken = tok;
 
               if (phase != 'exchange' || seat != actor) {
                 _sel.clear();
@@ -244,6 +254,10 @@ class _TablePageState extends State<TablePage> {
           break;
       }
     });
+    widget.ws.reconnected.listen((_) {
+      if (!mounted || seatToken == null) return;
+      widget.ws.send({"t":"rejoin","m":{"room": widget.roomId, "token": seatToken}});
+    });
   }
 
   void _leave() {
@@ -252,6 +266,9 @@ class _TablePageState extends State<TablePage> {
   }
 
   void _newHand() => widget.ws.send({"t":"new_hand","m":{"room": widget.roomId}});
+  void _toggleReady() => widget.ws.send({"t":"ready","m":{"room": widget.roomId}});
+  void _startGame() => widget.ws.send({"t":"start_game","m":{"room": widget.roomId}});
+  void _addBot() => widget.ws.send({"t":"add_bot","m":{"room": widget.roomId}});
   void _sendChat() {
     final t = chatCtrl.text.trim();
     if (t.isEmpty) return;
@@ -315,7 +332,10 @@ class _TablePageState extends State<TablePage> {
         title: Text('Table — ${widget.roomId}'),
         leading: IconButton(icon: const Icon(Icons.arrow_back), onPressed: _leave),
         actions: [
-          if (handOver) Padding(padding: const EdgeInsets.symmetric(horizontal: 8), child: FilledButton(onPressed: _newHand, child: const Text('New hand'))),
+          if (dealer == -1 && (phase ?? '').isEmpty) Padding(padding: const EdgeInsets.symmetric(horizontal: 8), child: OutlinedButton(onPressed: _toggleReady, child: Text((seat != null && seat! < ready.length && ready[seat!]) ? 'Not ready' : 'Ready'))),
+          if (dealer == -1 && (phase ?? '').isEmpty && host != null && host == seat) Padding(padding: const EdgeInsets.symmetric(horizontal: 8), child: OutlinedButton(onPressed: _addBot, child: const Text('Add bot'))),
+          if (dealer == -1 && (phase ?? '').isEmpty && host != null && host == seat) Padding(padding: const EdgeInsets.symmetric(horizontal: 8), child: FilledButton(onPressed: _startGame, child: const Text('Start'))),
+          if (handOver && host != null && host == seat) Padding(padding: const EdgeInsets.symmetric(horizontal: 8), child: FilledButton(onPressed: _newHand, child: const Text('New hand'))),
         ],
       ),
       body: Padding(
@@ -7,6 +7,12 @@ class WsService {
   final _messages = StreamController<Map<String, dynamic>>.broadcast();
   Stream<Map<String, dynamic>> get messages => _messages.stream;
 
+  // Fires each time the socket opens again after a drop; the server sees a
+  // new connection, so pages rejoin their tables from here.
+  final _reconnected = StreamController<void>.broadcast();
+  Stream<void> get reconnected => _reconnected.stream;
+  bool _everOpened = false;
+
   html.WebSocket? _ws;
   bool _isOpen = false;
   bool _manuallyClosed = false;
@@ -88,6 +94,8 @@ class WsService {
     ws.onOpen.listen((_) {
       _isOpen = true;
       _retryAttempts = 0;
+      final again = _everOpened;
+      _everOpened = true;
 
       // Flush outbox safely
       if (_outbox.isNotEmpty) {
@@ -103,6 +111,10 @@ class WsService {
         }
       }
 
+      if (again) {
+        _reconnected.add(null);
+      }
+
       // Start lightweight app-level ping to keep Safari connections warm
       _pingTimer?.cancel();
       _pingTimer = Timer.periodic(const Duration(seconds: 25), (_) {
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/backplane"
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/store"
 	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/ws"
 )
 
@@ -33,16 +42,124 @@ func main() {
 	}
 
 	hub := ws.NewHub(allow)
+
+	secret := os.Getenv("JWT_SECRET")
+	authMode := strings.ToLower(strings.TrimSpace(os.Getenv("AUTH_MODE")))
+	if authMode == "" {
+		authMode = ws.AuthOff
+		if secret != "" {
+			authMode = ws.AuthOptional
+		}
+	}
+	switch authMode {
+	case ws.AuthOff, ws.AuthOptional, ws.AuthRequired:
+	default:
+		log.Fatalf("AUTH_MODE must be off, optional or required, not %q", authMode)
+	}
+	if authMode != ws.AuthOff && secret == "" {
+		log.Fatalf("AUTH_MODE=%s needs JWT_SECRET", authMode)
+	}
+	// guest tokens would let anyone in, so required mode only issues them
+	// when asked to
+	guests := authMode == ws.AuthOptional
+	if v := strings.TrimSpace(os.Getenv("GUEST_TOKENS")); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("GUEST_TOKENS must be true or false, not %q", v)
+		}
+		guests = b
+	}
+	hub.SetAuth(ws.AuthConfig{
+		Mode:     authMode,
+		Secret:   []byte(secret),
+		Guests:   guests,
+		GuestTTL: envDuration("GUEST_TOKEN_TTL", 0),
+	})
+	log.Printf("Auth mode: %s", authMode)
+	if guests && authMode != ws.AuthOff {
+		log.Printf("Guest tokens: on")
+	}
+	var st store.Store = store.NewMemory()
+	if dir := strings.TrimSpace(os.Getenv("DATA_DIR")); dir != "" {
+		fs, err := store.OpenFile(dir)
+		if err != nil {
+			log.Fatalf("open store in %s: %v", dir, err)
+		}
+		st = fs
+		log.Printf("Storage: %s", dir)
+	} else {
+		log.Printf("Storage: memory (set DATA_DIR to keep data across restarts)")
+	}
+	hub.SetStore(st)
+	n, err := hub.RestoreRooms()
+	if err != nil {
+		log.Fatalf("restore rooms: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Restored %d rooms", n)
+	}
+	hub.StartSnapshots(context.Background(), envDuration("SNAPSHOT_INTERVAL", 30*time.Second))
+
+	var bp backplane.Backplane = backplane.NewLoopback()
+	if u := strings.TrimSpace(os.Getenv("REDIS_URL")); u != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		rb, err := backplane.DialRedis(ctx, u)
+		cancel()
+		if err != nil {
+			log.Fatalf("connect backplane %s: %v", u, err)
+		}
+		bp = rb
+	}
+	self := backplane.Instance{
+		ID:  strings.TrimSpace(os.Getenv("INSTANCE_ID")),
+		URL: strings.TrimSpace(os.Getenv("PUBLIC_WS_URL")),
+	}
+	if self.ID == "" {
+		host, _ := os.Hostname()
+		self.ID = host + ":" + port
+	}
+	if self.URL == "" {
+		self.URL = "ws://localhost:" + port + "/ws"
+	}
+	hub.SetBackplane(bp, self)
+	hub.StartCluster(context.Background(), envDuration("CLUSTER_INTERVAL", 0))
+	if _, ok := bp.(*backplane.Redis); ok {
+		log.Printf("Cluster: instance %s at %s", self.ID, self.URL)
+	}
+	if debug, _ := strconv.ParseBool(os.Getenv("DEBUG_INVARIANTS")); debug {
+		hub.SetDebug(true)
+		log.Printf("invariant checks on")
+	}
+
+	jc := ws.DefaultJanitorConfig()
+	jc.Interval = envDuration("JANITOR_INTERVAL", jc.Interval)
+	jc.EmptyTTL = envDuration("ROOM_EMPTY_TTL", jc.EmptyTTL)
+	jc.IdleTTL = envDuration("ROOM_IDLE_TTL", jc.IdleTTL)
+	jc.FinishedTTL = envDuration("ROOM_FINISHED_TTL", jc.FinishedTTL)
+	jc.NameTTL = envDuration("NAME_TTL", jc.NameTTL)
+	hub.StartJanitor(context.Background(), jc)
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		hub.ServeWS(w, r)
 	})
 
+	mux.HandleFunc("/auth/guest", hub.ServeGuestToken)
+
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("ok"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "draining": hub.Draining()})
 	})
 
+	mux.Handle("/metrics", hub.MetricsHandler())
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	mux.Handle("/admin/", hub.AdminHandler(adminToken))
+	if adminToken == "" {
+		log.Printf("Admin endpoints off (set ADMIN_TOKEN)")
+	}
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("Reusable Card Game Server running. WebSocket at /ws\n"))
 	})
@@ -52,7 +169,45 @@ func main() {
 	log.Printf("Allowed Origins: %v", allow)
 	log.Printf("WebSocket endpoint: ws://localhost:%s/ws", port)
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatalf("server error: %v", err)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	log.Printf("%v: shutting down", <-sig)
+	signal.Stop(sig) // a second signal kills at once
+
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 25*time.Second))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	if err := hub.Shutdown(ctx, envDuration("SHUTDOWN_NOTICE", 10*time.Second)); err != nil {
+		log.Printf("snapshot: %v", err)
+	}
+	if err := bp.Close(); err != nil {
+		log.Printf("close backplane: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		log.Printf("close store: %v", err)
+	}
+	log.Printf("bye")
+}
+
+// envDuration reads a Go duration ("90s", "15m") from the environment.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("ignoring %s=%q: %v", key, v, err)
+		return def
 	}
+	return d
 }
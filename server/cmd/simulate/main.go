@@ -0,0 +1,103 @@
+// Command simulate plays Mulatschak matches between bots in-process and
+// prints statistics for balancing house rules and catching engine bugs.
+//
+//	go run ./cmd/simulate -matches 2000 -seats 3 -bots heuristic,ismcts:200 -knock 0.2
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/ws"
+)
+
+func main() {
+	var cfg ws.SimConfig
+	var bots string
+	var asJSON bool
+	flag.IntVar(&cfg.Matches, "matches", 1000, "matches to play")
+	flag.IntVar(&cfg.Seats, "seats", 3, "players per table")
+	flag.IntVar(&cfg.Target, "target", 21, "a match ends when a seat's score reaches -target")
+	flag.IntVar(&cfg.MaxHands, "max-hands", 200, "hand limit per match")
+	flag.StringVar(&bots, "bots", "heuristic", "comma-separated bot kinds, one per seat (repeated)")
+	flag.Float64Var(&cfg.KnockRate, "knock", 0, "chance the first bidder knocks (0..1)")
+	flag.Int64Var(&cfg.Seed, "seed", 1, "random seed")
+	flag.BoolVar(&asJSON, "json", false, "print raw statistics as JSON")
+	flag.Parse()
+	for _, b := range strings.Split(bots, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			cfg.Bots = append(cfg.Bots, b)
+		}
+	}
+
+	st := ws.Simulate(cfg)
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(st)
+	} else {
+		report(st)
+	}
+	if st.Crashes > 0 || st.Stuck > 0 || st.Violations > 0 {
+		os.Exit(1)
+	}
+}
+
+func report(st ws.SimStats) {
+	fmt.Printf("matches %d (%d cut off), hands %d, crashes %d, stuck %d, invariant violations %d\n",
+		st.Matches, st.Unended, st.Hands, st.Crashes, st.Stuck, st.Violations)
+	for _, p := range st.Problems {
+		fmt.Println("  !", p)
+	}
+	if st.Hands == 0 {
+		return
+	}
+
+	fmt.Println("\nwinning bid     hands   share    made")
+	for b := 1; b <= 5; b++ {
+		fmt.Printf("  %d          %8d  %5.1f%%  %5.1f%%\n", b, st.Bids[b], pct(st.Bids[b], st.Hands), pct(st.BidsMade[b], st.Bids[b]))
+	}
+
+	plain := st.Hands - st.Knocked
+	fmt.Println("\nknocking        hands    made   avg swing")
+	fmt.Printf("  knocked    %8d  %5.1f%%  %8.2f\n", st.Knocked, pct(st.KnockedMade, st.Knocked), avg(st.KnockedSwing, st.Knocked))
+	fmt.Printf("  plain      %8d  %5.1f%%  %8.2f\n", plain, pct(st.PlainMade, plain), avg(st.PlainSwing, plain))
+
+	fmt.Println("\nposition        best hand")
+	for p, n := range st.HandWins {
+		label := fmt.Sprintf("+%d", p)
+		if p == 0 {
+			label = "first bidder"
+		}
+		if p == len(st.HandWins)-1 {
+			label = "dealer"
+		}
+		fmt.Printf("  %-12s  %5.1f%%\n", label, pct(n, st.Hands))
+	}
+	finished := st.Matches - st.Unended
+	fmt.Println("\nseat            match wins")
+	for s, n := range st.MatchWins {
+		fmt.Printf("  %-12d  %5.1f%%\n", s, pct(n, finished))
+	}
+
+	fmt.Printf("\nper hand: %.1f decisions, %.1f tricks, %.2f seats stayed home\n",
+		avg(st.Actions, st.Hands), avg(st.Tricks, st.Hands), avg(st.StayedHome, st.Hands))
+	fmt.Printf("per match: %.1f hands\n", avg(st.MatchHands, finished))
+}
+
+func pct(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+func avg(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forge builds a token from a raw header and claims, signed with secret
+// under HS256 whatever the header says.
+func forge(t *testing.T, secret []byte, hdr string, c Claims) string {
+	t.Helper()
+	p, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing := b64.EncodeToString([]byte(hdr)) + "." + b64.EncodeToString(p)
+	return signing + "." + b64.EncodeToString(mac(secret, signing))
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Unix(1_700_000_000, 0)
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	sign := func(c Claims) string {
+		tok, err := Sign(secret, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	valid := sign(Claims{Sub: "u1", Name: "Ann", Exp: at(time.Hour)})
+	parts := strings.Split(valid, ".")
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"no expiry", sign(Claims{Sub: "u1"}), nil},
+		{"alg none", b64.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", ErrAlgorithm},
+		{"alg none, signed", forge(t, secret, `{"alg":"none"}`, Claims{Sub: "u1"}), ErrAlgorithm},
+		{"wrong alg", forge(t, secret, `{"alg":"HS512","typ":"JWT"}`, Claims{Sub: "u1"}), ErrAlgorithm},
+		{"lowercase alg", forge(t, secret, `{"alg":"hs256"}`, Claims{Sub: "u1"}), ErrAlgorithm},
+		{"other secret", forge(t, []byte("other"), `{"alg":"HS256"}`, Claims{Sub: "u1"}), ErrSignature},
+		{"tampered claims", parts[0] + "." + b64.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2], ErrSignature},
+		{"no signature", parts[0] + "." + parts[1] + ".", ErrSignature},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!", ErrMalformed},
+		{"two parts", parts[0] + "." + parts[1], ErrMalformed},
+		{"bad header", "e30x." + parts[1] + "." + parts[2], ErrMalformed},
+		{"expired", sign(Claims{Sub: "u1", Exp: at(-Leeway - time.Second)}), ErrExpired},
+		{"expired within leeway", sign(Claims{Sub: "u1", Exp: at(-Leeway + time.Second)}), nil},
+		{"not yet", sign(Claims{Sub: "u1", Nbf: at(Leeway + time.Second)}), ErrNotYet},
+		{"not yet within leeway", sign(Claims{Sub: "u1", Nbf: at(Leeway - time.Second)}), nil},
+		{"missing sub", sign(Claims{Name: "Ann", Exp: at(time.Hour)}), ErrNoSubject},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Verify(secret, tc.token, now)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if err == nil && c.Sub != "u1" {
+				t.Fatalf("sub = %q", c.Sub)
+			}
+		})
+	}
+}
+
+func TestSignRoundTrip(t *testing.T) {
+	secret := []byte("test-secret")
+	in := Claims{Sub: "guest-1", Name: "Zoë", Guest: true, Iat: 100, Exp: 200}
+	tok, err := Sign(secret, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Verify(secret, tok, time.Unix(150, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
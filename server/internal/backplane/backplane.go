@@ -0,0 +1,47 @@
+// Package backplane lets several server instances share one lobby. Each
+// room lives on the instance that created it; the backplane records who
+// owns what, where each instance can be reached and which tables each one
+// lists, so an instance can send a client on to the owner of a room it
+// doesn't hold. Loopback keeps all of it in process, for tests and single
+// instances; Redis shares it through a Redis-compatible server (REDIS_URL).
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrClosed = errors.New("backplane: closed")
+
+// Instance is one server process.
+type Instance struct {
+	ID  string `json:"id"`
+	URL string `json:"url"` // websocket URL clients reconnect to
+}
+
+// Backplane is the state instances share. Every entry lapses after its
+// ttl unless refreshed, so a crashed instance drops out on its own; ttl <= 0
+// keeps it until removed. Implementations are safe for concurrent use.
+type Backplane interface {
+	// Register announces in; Instances lists those still registered.
+	Register(ctx context.Context, in Instance, ttl time.Duration) error
+	Instances(ctx context.Context) ([]Instance, error)
+
+	// Claim makes owner the owner of key (a room id or invite code) unless
+	// another instance holds it, and returns whoever owns it now. Claiming
+	// a key one already owns refreshes its ttl.
+	Claim(ctx context.Context, key, owner string, ttl time.Duration) (string, error)
+	// Owner returns the owner of key, "" if there is none.
+	Owner(ctx context.Context, key string) (string, error)
+	// Release gives up key if owner holds it.
+	Release(ctx context.Context, key, owner string) error
+
+	// PutTables replaces owner's lobby listing; Tables returns every
+	// instance's listing by instance id.
+	PutTables(ctx context.Context, owner string, list json.RawMessage, ttl time.Duration) error
+	Tables(ctx context.Context) (map[string]json.RawMessage, error)
+
+	Close() error
+}
@@ -0,0 +1,139 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Loopback is a backplane inside one process. Hubs sharing one Loopback
+// behave like instances sharing a Redis.
+type Loopback struct {
+	mu        sync.Mutex
+	instances map[string]entry
+	claims    map[string]entry
+	tables    map[string]entry
+	closed    bool
+}
+
+type entry struct {
+	val string
+	exp time.Time // zero: never
+}
+
+func (e entry) live(now time.Time) bool {
+	return e.exp.IsZero() || now.Before(e.exp)
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func NewLoopback() *Loopback {
+	return &Loopback{
+		instances: make(map[string]entry),
+		claims:    make(map[string]entry),
+		tables:    make(map[string]entry),
+	}
+}
+
+// Register also sweeps lapsed entries, as instances call it regularly.
+func (b *Loopback) Register(_ context.Context, in Instance, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	now := time.Now()
+	for _, m := range []map[string]entry{b.instances, b.claims, b.tables} {
+		for k, e := range m {
+			if !e.live(now) {
+				delete(m, k)
+			}
+		}
+	}
+	b.instances[in.ID] = entry{in.URL, expiry(ttl)}
+	return nil
+}
+
+func (b *Loopback) Instances(context.Context) ([]Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var out []Instance
+	for id, e := range b.instances {
+		if e.live(now) {
+			out = append(out, Instance{ID: id, URL: e.val})
+		} else {
+			delete(b.instances, id)
+		}
+	}
+	return out, nil
+}
+
+func (b *Loopback) Claim(_ context.Context, key, owner string, ttl time.Duration) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return "", ErrClosed
+	}
+	if e, ok := b.claims[key]; ok && e.live(time.Now()) && e.val != owner {
+		return e.val, nil
+	}
+	b.claims[key] = entry{owner, expiry(ttl)}
+	return owner, nil
+}
+
+func (b *Loopback) Owner(_ context.Context, key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e, ok := b.claims[key]; ok && e.live(time.Now()) {
+		return e.val, nil
+	}
+	return "", nil
+}
+
+func (b *Loopback) Release(_ context.Context, key, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e, ok := b.claims[key]; ok && e.val == owner {
+		delete(b.claims, key)
+	}
+	return nil
+}
+
+func (b *Loopback) PutTables(_ context.Context, owner string, list json.RawMessage, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	b.tables[owner] = entry{string(list), expiry(ttl)}
+	return nil
+}
+
+func (b *Loopback) Tables(context.Context) (map[string]json.RawMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	out := make(map[string]json.RawMessage, len(b.tables))
+	for id, e := range b.tables {
+		if in, ok := b.instances[id]; ok && in.live(now) && e.live(now) {
+			out[id] = json.RawMessage(e.val)
+		} else {
+			delete(b.tables, id)
+		}
+	}
+	return out, nil
+}
+
+func (b *Loopback) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,333 @@
+package backplane
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redis is a backplane on a Redis-compatible server, spoken to over one
+// connection with the plain RESP protocol and only basic commands (no
+// scripts or modules), so small stand-ins work too. Keys:
+//
+//	cardgame:instances        set of instance ids
+//	cardgame:instance:<id>    its URL
+//	cardgame:owner:<key>      owning instance id
+//	cardgame:tables:<id>      its lobby listing
+type Redis struct {
+	addr, user, pass string
+	db               int
+
+	mu     sync.Mutex // one command at a time on conn
+	conn   net.Conn
+	rd     *bufio.Reader
+	closed bool
+}
+
+const (
+	keyPrefix    = "cardgame:"
+	redisTimeout = 3 * time.Second // per command, when ctx has no deadline
+)
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// DialRedis connects to a redis://[user:password@]host[:port][/db] URL.
+func DialRedis(ctx context.Context, rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("backplane: unsupported scheme %q", u.Scheme)
+	}
+	r := &Redis{addr: u.Host, user: u.User.Username()}
+	r.pass, _ = u.User.Password()
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		if r.db, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("backplane: bad database %q", p)
+		}
+	}
+	if _, err := r.do(ctx, "PING"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// do sends one command and returns its reply: a string, int64, []any or
+// nil. A broken connection is redialled on the next call.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrClosed
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if r.conn == nil {
+		if err := r.connect(ctx, deadline); err != nil {
+			return nil, err
+		}
+	}
+	r.conn.SetDeadline(deadline)
+	v, err := r.roundTrip(args)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return v, err
+}
+
+func (r *Redis) connect(ctx context.Context, deadline time.Time) error {
+	d := net.Dialer{Deadline: deadline}
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline)
+	r.conn, r.rd = conn, bufio.NewReader(conn)
+	if r.pass != "" {
+		args := []string{"AUTH", r.pass}
+		if r.user != "" {
+			args = []string{"AUTH", r.user, r.pass}
+		}
+		_, err = r.roundTrip(args)
+	}
+	if err == nil && r.db != 0 {
+		_, err = r.roundTrip([]string{"SELECT", strconv.Itoa(r.db)})
+	}
+	if err != nil {
+		conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+func (r *Redis) roundTrip(args []string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return r.read()
+}
+
+func (r *Redis) read() (any, error) {
+	line, err := r.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("backplane: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = r.read(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("backplane: unexpected reply %q", line)
+}
+
+// str is a bulk or simple string reply, "" for nil.
+func str(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// withTTL appends PX to a SET when ttl is set.
+func withTTL(args []string, ttl time.Duration) []string {
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	return args
+}
+
+func (r *Redis) Register(ctx context.Context, in Instance, ttl time.Duration) error {
+	if _, err := r.do(ctx, withTTL([]string{"SET", keyPrefix + "instance:" + in.ID, in.URL}, ttl)...); err != nil {
+		return err
+	}
+	_, err := r.do(ctx, "SADD", keyPrefix+"instances", in.ID)
+	return err
+}
+
+// members returns the registered instance ids with the values of
+// <kind>:<id>, dropping instances whose registration has lapsed.
+func (r *Redis) members(ctx context.Context, kind string) ([]string, []any, error) {
+	v, err := r.do(ctx, "SMEMBERS", keyPrefix+"instances")
+	if err != nil {
+		return nil, nil, err
+	}
+	list, _ := v.([]any)
+	if len(list) == 0 {
+		return nil, nil, nil
+	}
+	ids := make([]string, len(list))
+	args := []string{"MGET"}
+	for i, id := range list {
+		ids[i] = str(id)
+		args = append(args, keyPrefix+"instance:"+ids[i])
+	}
+	if kind != "instance" {
+		for _, id := range ids {
+			args = append(args, keyPrefix+kind+":"+id)
+		}
+	}
+	v, err = r.do(ctx, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	vals, _ := v.([]any)
+	if len(vals) != len(args)-1 {
+		return nil, nil, errors.New("backplane: short MGET reply")
+	}
+	var live []string
+	var out []any
+	for i, id := range ids {
+		if vals[i] == nil {
+			r.do(ctx, "SREM", keyPrefix+"instances", id)
+			continue
+		}
+		live = append(live, id)
+		out = append(out, vals[len(vals)-len(ids)+i])
+	}
+	return live, out, nil
+}
+
+func (r *Redis) Instances(ctx context.Context) ([]Instance, error) {
+	ids, urls, err := r.members(ctx, "instance")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Instance, len(ids))
+	for i, id := range ids {
+		out[i] = Instance{ID: id, URL: str(urls[i])}
+	}
+	return out, nil
+}
+
+func (r *Redis) Claim(ctx context.Context, key, owner string, ttl time.Duration) (string, error) {
+	k := keyPrefix + "owner:" + key
+	for range 2 {
+		v, err := r.do(ctx, withTTL([]string{"SET", k, owner, "NX"}, ttl)...)
+		if err != nil {
+			return "", err
+		}
+		if v != nil {
+			return owner, nil
+		}
+		v, err = r.do(ctx, "GET", k)
+		if err != nil {
+			return "", err
+		}
+		switch cur := str(v); cur {
+		case "":
+			continue // lapsed in between: try again
+		case owner:
+			if ttl > 0 {
+				_, err = r.do(ctx, "PEXPIRE", k, strconv.FormatInt(ttl.Milliseconds(), 10))
+			} else {
+				_, err = r.do(ctx, "PERSIST", k)
+			}
+			return owner, err
+		default:
+			return cur, nil
+		}
+	}
+	return "", fmt.Errorf("backplane: claim %s: contended", key)
+}
+
+func (r *Redis) Owner(ctx context.Context, key string) (string, error) {
+	v, err := r.do(ctx, "GET", keyPrefix+"owner:"+key)
+	return str(v), err
+}
+
+// Release checks and deletes in two steps; a key only changes hands after
+// lapsing, so at worst a claim made in between is dropped and redone on
+// the other instance's next refresh.
+func (r *Redis) Release(ctx context.Context, key, owner string) error {
+	k := keyPrefix + "owner:" + key
+	v, err := r.do(ctx, "GET", k)
+	if err != nil || str(v) != owner {
+		return err
+	}
+	_, err = r.do(ctx, "DEL", k)
+	return err
+}
+
+func (r *Redis) PutTables(ctx context.Context, owner string, list json.RawMessage, ttl time.Duration) error {
+	_, err := r.do(ctx, withTTL([]string{"SET", keyPrefix + "tables:" + owner, string(list)}, ttl)...)
+	return err
+}
+
+func (r *Redis) Tables(ctx context.Context) (map[string]json.RawMessage, error) {
+	ids, lists, err := r.members(ctx, "tables")
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage, len(ids))
+	for i, id := range ids {
+		if s := str(lists[i]); s != "" {
+			out[id] = json.RawMessage(s)
+		}
+	}
+	return out, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
@@ -0,0 +1,318 @@
+package backplane
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisRead(t *testing.T) {
+	for _, tc := range []struct {
+		name, raw string
+		want      any
+		err       bool
+	}{
+		{"simple", "+OK\r\n", "OK", false},
+		{"error", "-ERR wrong type\r\n", nil, true},
+		{"integer", ":42\r\n", int64(42), false},
+		{"bulk", "$5\r\nhello\r\n", "hello", false},
+		{"bulk with CRLF inside", "$4\r\na\r\nb\r\n", "a\r\nb", false},
+		{"nil bulk", "$-1\r\n", nil, false},
+		{"nil array", "*-1\r\n", nil, false},
+		{"array", "*3\r\n$1\r\na\r\n$-1\r\n:7\r\n", []any{"a", nil, int64(7)}, false},
+		{"nested", "*1\r\n*1\r\n+x\r\n", []any{[]any{"x"}}, false},
+		{"empty line", "\r\n", nil, true},
+		{"unknown type", "?what\r\n", nil, true},
+		{"short bulk", "$5\r\nhi\r\n", nil, true},
+		{"short array", "*2\r\n+a\r\n", nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &Redis{rd: bufio.NewReader(strings.NewReader(tc.raw))}
+			v, err := r.read()
+			if (err != nil) != tc.err {
+				t.Fatalf("err = %v, want error %v", err, tc.err)
+			}
+			if !tc.err && !reflect.DeepEqual(v, tc.want) {
+				t.Fatalf("got %#v, want %#v", v, tc.want)
+			}
+		})
+	}
+	var re redisError
+	r := &Redis{rd: bufio.NewReader(strings.NewReader("-NOPERM\r\n"))}
+	if _, err := r.read(); !errors.As(err, &re) || string(re) != "NOPERM" {
+		t.Fatalf("error reply: %v", err)
+	}
+}
+
+// fakeRedis serves the commands the backplane uses from memory.
+type fakeRedis struct {
+	mu   sync.Mutex
+	strs map[string]string
+	exp  map[string]time.Time
+	sets map[string]map[string]bool
+	log  [][]string // every command, in order
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{strs: map[string]string{}, exp: map[string]time.Time{}, sets: map[string]map[string]bool{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = rd.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(rd, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		io.WriteString(conn, f.exec(args))
+	}
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+// get returns key's value, dropping it if it has lapsed. f.mu is held.
+func (f *fakeRedis) get(key string) (string, bool) {
+	if e, ok := f.exp[key]; ok && !time.Now().Before(e) {
+		delete(f.strs, key)
+		delete(f.exp, key)
+	}
+	v, ok := f.strs[key]
+	return v, ok
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, args)
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		key, nx, ttl := args[1], false, time.Duration(0)
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			}
+		}
+		if _, ok := f.get(key); ok && nx {
+			return "$-1\r\n"
+		}
+		f.strs[key] = args[2]
+		delete(f.exp, key)
+		if ttl > 0 {
+			f.exp[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.get(args[1]); ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "MGET":
+		out := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, k := range args[1:] {
+			if v, ok := f.get(k); ok {
+				out += bulk(v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	case "DEL":
+		_, ok := f.get(args[1])
+		delete(f.strs, args[1])
+		delete(f.exp, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PEXPIRE":
+		ms, _ := strconv.Atoi(args[2])
+		f.exp[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "PERSIST":
+		delete(f.exp, args[1])
+		return ":1\r\n"
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = map[string]bool{}
+		}
+		f.sets[args[1]][args[2]] = true
+		return ":1\r\n"
+	case "SREM":
+		delete(f.sets[args[1]], args[2])
+		return ":1\r\n"
+	case "SMEMBERS":
+		var members []string
+		for m := range f.sets[args[1]] {
+			members = append(members, m)
+		}
+		slices.Sort(members)
+		out := fmt.Sprintf("*%d\r\n", len(members))
+		for _, m := range members {
+			out += bulk(m)
+		}
+		return out
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) commands() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.log)
+}
+
+func TestRedisDial(t *testing.T) {
+	f, addr := startFakeRedis(t)
+	r, err := DialRedis(context.Background(), "redis://cards:secret@"+addr+"/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := [][]string{{"AUTH", "cards", "secret"}, {"SELECT", "2"}, {"PING"}}
+	if got := f.commands(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands %q, want %q", got, want)
+	}
+	if _, err := DialRedis(context.Background(), "http://"+addr); err == nil {
+		t.Fatal("dialled a non-redis URL")
+	}
+}
+
+func TestRedisBackplane(t *testing.T) {
+	ctx := context.Background()
+	f, addr := startFakeRedis(t)
+	r, err := DialRedis(ctx, "redis://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// members pairs each live instance with its value and forgets lapsed ones
+	for _, in := range []Instance{{"a", "ws://a"}, {"b", "ws://b"}, {"c", "ws://c"}} {
+		if err := r.Register(ctx, in, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.mu.Lock()
+	delete(f.strs, keyPrefix+"instance:b")
+	f.mu.Unlock()
+	ins, err := r.Instances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Instance{{"a", "ws://a"}, {"c", "ws://c"}}; !reflect.DeepEqual(ins, want) {
+		t.Fatalf("instances %v, want %v", ins, want)
+	}
+	f.mu.Lock()
+	_, kept := f.sets[keyPrefix+"instances"]["b"]
+	f.mu.Unlock()
+	if kept {
+		t.Fatal("lapsed instance b still in the set")
+	}
+
+	if err := r.PutTables(ctx, "a", json.RawMessage(`[{"id":"r1"}]`), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := r.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || string(tables["a"]) != `[{"id":"r1"}]` {
+		t.Fatalf("tables %v", tables)
+	}
+
+	claim := func(key, owner string, ttl time.Duration, want string) {
+		t.Helper()
+		got, err := r.Claim(ctx, key, owner, ttl)
+		if err != nil || got != want {
+			t.Fatalf("Claim(%s, %s) = %q, %v; want %q", key, owner, got, err, want)
+		}
+	}
+	claim("r1", "a", time.Minute, "a")
+	claim("r1", "c", time.Minute, "a") // held
+	claim("r1", "a", 0, "a")           // refreshed, now without expiry
+	f.mu.Lock()
+	_, expires := f.exp[keyPrefix+"owner:r1"]
+	f.mu.Unlock()
+	if expires {
+		t.Fatal("claim with ttl 0 still expires")
+	}
+	claim("r2", "c", 20*time.Millisecond, "c")
+	time.Sleep(30 * time.Millisecond)
+	claim("r2", "a", time.Minute, "a") // lapsed, so free
+
+	if err := r.Release(ctx, "r1", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := r.Owner(ctx, "r1"); o != "a" {
+		t.Fatalf("release by a non-owner dropped the claim: owner %q", o)
+	}
+	if err := r.Release(ctx, "r1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := r.Owner(ctx, "r1"); o != "" {
+		t.Fatalf("owner after release %q", o)
+	}
+
+	// a broken connection is redialled on the next command
+	r.mu.Lock()
+	r.conn.Close()
+	r.mu.Unlock()
+	if _, err := r.Owner(ctx, "r2"); err == nil {
+		t.Fatal("no error on a closed connection")
+	}
+	if o, err := r.Owner(ctx, "r2"); err != nil || o != "a" {
+		t.Fatalf("after reconnect: %q, %v", o, err)
+	}
+
+	r.Close()
+	if _, err := r.Owner(ctx, "r2"); err != ErrClosed {
+		t.Fatalf("closed: %v", err)
+	}
+}
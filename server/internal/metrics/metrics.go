@@ -0,0 +1,219 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, without a client library. Every
+// metric has at most one label; an empty label name makes it a plain
+// series.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, from half a
+// millisecond to a few seconds.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Registry is a set of metrics written out in the order they were added.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+}
+
+// header is a metric's name, help and label.
+type header struct {
+	name, help, label string
+}
+
+func (h header) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", h.name, h.help, h.name, typ)
+}
+
+// labels renders {label="v"} plus any extra pairs, or nothing.
+func (h header) labels(v string, extra ...string) string {
+	var parts []string
+	if h.label != "" {
+		parts = append(parts, h.label+`="`+escape(v)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string { return escaper.Replace(v) }
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing count per label value.
+type Counter struct {
+	header
+	mu   sync.Mutex
+	vals map[string]float64
+}
+
+func (r *Registry) Counter(name, help, label string) *Counter {
+	c := &Counter{header: header{name, help, label}, vals: make(map[string]float64)}
+	r.add(c)
+	return c
+}
+
+func (c *Counter) Inc(v string) { c.Add(v, 1) }
+
+func (c *Counter) Add(v string, n float64) {
+	c.mu.Lock()
+	c.vals[v] += n
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range sortedKeys(c.vals) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(v), formatFloat(c.vals[v]))
+	}
+}
+
+// gauge is read when the metrics are written.
+type gauge struct {
+	header
+	typ  string
+	read func() map[string]float64
+}
+
+// Gauge adds a gauge whose values, by label value, come from read at each
+// scrape.
+func (r *Registry) Gauge(name, help, label string, read func() map[string]float64) {
+	r.add(&gauge{header{name, help, label}, "gauge", read})
+}
+
+// CounterFunc adds a counter kept elsewhere, read like a Gauge.
+func (r *Registry) CounterFunc(name, help, label string, read func() map[string]float64) {
+	r.add(&gauge{header{name, help, label}, "counter", read})
+}
+
+func (g *gauge) write(w *bufio.Writer) {
+	g.writeHeader(w, g.typ)
+	vals := g.read()
+	for _, v := range sortedKeys(vals) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels(v), formatFloat(vals[v]))
+	}
+}
+
+// Histogram counts observations into buckets per label value.
+type Histogram struct {
+	header
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*series
+}
+
+type series struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	n      uint64
+}
+
+func (r *Registry) Histogram(name, help, label string, buckets []float64) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	h := &Histogram{header: header{name, help, label}, buckets: buckets, series: make(map[string]*series)}
+	r.add(h)
+	return h
+}
+
+func (h *Histogram) Observe(v string, x float64) {
+	i, _ := slices.BinarySearch(h.buckets, x)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[v]
+	if s == nil {
+		s = &series{counts: make([]uint64, len(h.buckets))}
+		h.series[v] = s
+	}
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += x
+	s.n++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, v := range sortedKeys(h.series) {
+		s := h.series[v]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(v, "le", formatFloat(le)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(v, "le", "+Inf"), s.n)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(v), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(v), s.n)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Write writes every metric in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	ms := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, m := range ms {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry, e.g. at /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a durable store in a data directory. Users and rooms are
+// appended as JSON lines to store.log and replayed into memory on open;
+// the log is compacted down to the current state on open and whenever it
+// has grown to twice that size. Results and events only ever grow, so they
+// stay on disk: results.log holds every result and events/<room>.log each
+// room's action log, read back when asked for.
+type File struct {
+	mem       *Memory    // users and rooms
+	mu        sync.Mutex // serialises appends, so the log matches memory
+	dir, path string
+	f         *os.File
+	size      int64 // of store.log
+	compactAt int64
+	results   *os.File
+}
+
+// record is one line of store.log.
+type record struct {
+	Op     string  `json:"op"` // user | room | del_room; result and event only in old logs
+	User   *User   `json:"user,omitempty"`
+	Room   *Room   `json:"room,omitempty"`
+	ID     string  `json:"id,omitempty"`
+	Result *Result `json:"result,omitempty"`
+	Event  *Event  `json:"event,omitempty"`
+}
+
+const (
+	// maxRecord bounds one log line; room snapshots are the largest.
+	maxRecord = 16 << 20
+	// minCompact is the smallest log worth compacting.
+	minCompact = 4 << 20
+)
+
+// OpenFile opens or creates the store in dir.
+func OpenFile(dir string) (*File, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "events"), 0o755); err != nil {
+		return nil, err
+	}
+	s := &File{mem: NewMemory(), dir: dir, path: filepath.Join(dir, "store.log")}
+	var err error
+	s.results, err = os.OpenFile(filepath.Join(dir, "results.log"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err == nil {
+		err = endLine(s.results)
+	}
+	if err != nil {
+		if s.results != nil {
+			s.results.Close()
+		}
+		return nil, err
+	}
+	if err := s.replay(); err != nil {
+		s.results.Close()
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		s.results.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay loads store.log into memory. Results and events in logs written
+// before they had files of their own are moved there; the compaction that
+// follows drops them from store.log.
+func (s *File) replay() error {
+	var results []Result
+	var events []Event
+	err := scanLines(s.path, func(line int, b []byte) {
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			// most likely a write cut short by a crash
+			log.Printf("store: skipping bad record at %s:%d: %v", s.path, line, err)
+			return
+		}
+		switch {
+		case rec.Op == "result" && rec.Result != nil:
+			results = append(results, *rec.Result)
+		case rec.Op == "event" && rec.Event != nil:
+			events = append(events, *rec.Event)
+		default:
+			s.apply(rec)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := s.appendResult(r); err != nil {
+			return err
+		}
+	}
+	return s.appendEvents(events)
+}
+
+// scanLines calls fn with each line of the file at path; a missing file
+// has none.
+func scanLines(path string, fn func(line int, b []byte)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), maxRecord)
+	line := 0
+	for sc.Scan() {
+		line++
+		fn(line, sc.Bytes())
+	}
+	return sc.Err()
+}
+
+// endLine ends a line cut short by a crash at the end of f, so the next
+// append starts a line of its own rather than spoiling it too.
+func endLine(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+func (s *File) apply(rec record) {
+	m := s.mem
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch rec.Op {
+	case "user":
+		if rec.User != nil {
+			m.putUser(*rec.User)
+		}
+	case "room":
+		if rec.Room != nil {
+			m.rooms[rec.Room.ID] = *rec.Room
+		}
+	case "del_room":
+		delete(m.rooms, rec.ID)
+	}
+}
+
+// compact rewrites store.log as the current state and goes on appending to
+// the new log. The new log replaces the old one only once it is on disk.
+func (s *File) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("store: compact: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	m := s.mem
+	m.mu.RLock()
+	for _, u := range m.users {
+		err = errors.Join(err, enc.Encode(record{Op: "user", User: &u}))
+	}
+	for _, r := range m.rooms {
+		err = errors.Join(err, enc.Encode(record{Op: "room", Room: &r}))
+	}
+	m.mu.RUnlock()
+	err = errors.Join(err, w.Flush(), f.Sync())
+	var size int64
+	if err == nil {
+		size, err = f.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("store: compact: %w", err)
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, s.size, s.compactAt = f, size, max(minCompact, 2*size)
+	return nil
+}
+
+// write appends rec to store.log and then applies it, compacting the log
+// once it has outgrown the state it holds.
+func (s *File) write(rec record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	n, err := s.f.Write(append(b, '\n'))
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	s.apply(rec)
+	if s.size >= s.compactAt {
+		if err := s.compact(); err != nil {
+			// the old log is still whole; try again after the next write
+			log.Printf("%v", err)
+		}
+	}
+	return nil
+}
+
+func (s *File) PutUser(u User) error       { return s.write(record{Op: "user", User: &u}) }
+func (s *File) SaveRoom(r Room) error      { return s.write(record{Op: "room", Room: &r}) }
+func (s *File) DeleteRoom(id string) error { return s.write(record{Op: "del_room", ID: id}) }
+
+func (s *File) User(id string) (User, bool, error) { return s.mem.User(id) }
+func (s *File) Rooms() ([]Room, error)             { return s.mem.Rooms() }
+
+func (s *File) AddResult(r Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	return s.appendResult(r)
+}
+
+func (s *File) appendResult(r Result) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.results.Write(append(b, '\n'))
+	return err
+}
+
+// Results reads results.log.
+func (s *File) Results(room string) ([]Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+	var out []Result
+	err := scanLines(s.results.Name(), func(line int, b []byte) {
+		var r Result
+		if err := json.Unmarshal(b, &r); err != nil {
+			log.Printf("store: skipping bad result at %s:%d: %v", s.results.Name(), line, err)
+			return
+		}
+		if room == "" || r.Room == room {
+			out = append(out, r)
+		}
+	})
+	return out, err
+}
+
+func (s *File) AppendEvents(ev ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	return s.appendEvents(ev)
+}
+
+// appendEvents adds ev to their rooms' logs, a run of one room's events at
+// a time.
+func (s *File) appendEvents(ev []Event) error {
+	for len(ev) > 0 {
+		n := 1
+		for n < len(ev) && ev[n].Room == ev[0].Room {
+			n++
+		}
+		var buf []byte
+		for _, e := range ev[:n] {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			buf = append(append(buf, b...), '\n')
+		}
+		f, err := os.OpenFile(s.eventsPath(ev[0].Room), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		err = endLine(f)
+		if err == nil {
+			_, err = f.Write(buf)
+		}
+		if err := errors.Join(err, f.Close()); err != nil {
+			return err
+		}
+		ev = ev[n:]
+	}
+	return nil
+}
+
+// eventsPath is room's log; the escaping keeps any room ID inside events/.
+func (s *File) eventsPath(room string) string {
+	return filepath.Join(s.dir, "events", url.PathEscape(room)+".log")
+}
+
+// Events reads room's log.
+func (s *File) Events(room string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+	var out []Event
+	path := s.eventsPath(room)
+	err := scanLines(path, func(line int, b []byte) {
+		var e Event
+		if err := json.Unmarshal(b, &e); err != nil {
+			log.Printf("store: skipping bad event at %s:%d: %v", path, line, err)
+			return
+		}
+		out = append(out, e)
+	})
+	return out, err
+}
+
+// Close flushes the logs to disk.
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := errors.Join(s.f.Sync(), s.f.Close(), s.results.Sync(), s.results.Close())
+	s.f = nil
+	s.mem.Close()
+	return err
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openFile(t *testing.T, dir string) *File {
+	t.Helper()
+	s, err := OpenFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// check compares what s holds with the users, rooms, results and events
+// given; rooms by ID and data only.
+func check(t *testing.T, s *File, users []User, rooms map[string]string, results []Result, events map[string][]Event) {
+	t.Helper()
+	for _, want := range users {
+		u, ok, err := s.User(want.ID)
+		if err != nil || !ok || !reflect.DeepEqual(u, want) {
+			t.Fatalf("user %s = %+v, %v, %v; want %+v", want.ID, u, ok, err, want)
+		}
+	}
+	rs, err := s.Rooms()
+	must(t, err)
+	got := map[string]string{}
+	for _, r := range rs {
+		got[r.ID] = string(r.Data)
+	}
+	if !reflect.DeepEqual(got, rooms) {
+		t.Fatalf("rooms %v, want %v", got, rooms)
+	}
+	res, err := s.Results("")
+	must(t, err)
+	if !reflect.DeepEqual(res, results) {
+		t.Fatalf("results %+v, want %+v", res, results)
+	}
+	for room, want := range events {
+		ev, err := s.Events(room)
+		must(t, err)
+		if !reflect.DeepEqual(ev, want) {
+			t.Fatalf("events of %s %+v, want %+v", room, ev, want)
+		}
+	}
+}
+
+var t0 = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := openFile(t, dir)
+	ann := User{ID: "u1", Name: "Ann", Created: t0, Seen: t0}
+	must(t, s.PutUser(ann))
+	ann.Name, ann.Seen = "Anne", t0.Add(time.Hour)
+	must(t, s.PutUser(User{ID: "u1", Name: "Anne", Created: t0.Add(time.Hour), Seen: ann.Seen}))
+	guest := User{ID: "guest-1", Guest: true, Created: t0, Seen: t0}
+	must(t, s.PutUser(guest))
+	must(t, s.SaveRoom(Room{ID: "r1", Data: json.RawMessage(`{"v":1}`), Saved: t0}))
+	must(t, s.SaveRoom(Room{ID: "r1", Data: json.RawMessage(`{"v":2}`), Saved: t0}))
+	must(t, s.SaveRoom(Room{ID: "r2", Data: json.RawMessage(`{}`), Saved: t0}))
+	must(t, s.DeleteRoom("r2"))
+	results := []Result{
+		{Room: "r1", At: t0, Players: []string{"u1", "guest-1", ""}, Tricks: []int{3, 2, 0}, Scores: []int{-3, -2, 0}, Declarer: 0, Bid: 3},
+		{Room: "a/b", At: t0, Players: []string{"u1"}, Tricks: []int{0}, Scores: []int{10}, Declarer: 0, Bid: 5, Trump: "hearts", Knocked: true},
+	}
+	for _, r := range results {
+		must(t, s.AddResult(r))
+	}
+	events := map[string][]Event{
+		"r1":  {{Room: "r1", Seat: 0, Action: "bid", Detail: "3", At: t0}, {Room: "r1", Seat: 1, Action: "pass", At: t0}},
+		"a/b": {{Room: "a/b", Seat: 0, Action: "play", Detail: "Weli", At: t0}},
+	}
+	must(t, s.AppendEvents(events["r1"][0], events["a/b"][0], events["r1"][1]))
+	rooms := map[string]string{"r1": `{"v":2}`}
+	check(t, s, []User{ann, guest}, rooms, results, events)
+	must(t, s.Close())
+	if err := s.PutUser(ann); err != ErrClosed {
+		t.Fatalf("write after close: %v", err)
+	}
+
+	s = openFile(t, dir)
+	check(t, s, []User{ann, guest}, rooms, results, events)
+	if _, err := os.Stat(filepath.Join(dir, "events", "a%2Fb.log")); err != nil {
+		t.Fatalf("events of a/b not kept inside events/: %v", err)
+	}
+}
+
+func TestFileCompact(t *testing.T) {
+	dir := t.TempDir()
+	// a log from before results and events had files of their own
+	res := Result{Room: "r1", At: t0, Players: []string{"u1"}, Tricks: []int{1}, Scores: []int{-1}, Bid: 1}
+	ev := Event{Room: "r1", Action: "deal", At: t0}
+	var old []string
+	for _, rec := range []record{
+		{Op: "user", User: &User{ID: "u1", Name: "Ann", Created: t0, Seen: t0}},
+		{Op: "result", Result: &res},
+		{Op: "event", Event: &ev},
+	} {
+		b, _ := json.Marshal(rec)
+		old = append(old, string(b))
+	}
+	must(t, os.WriteFile(filepath.Join(dir, "store.log"), []byte(strings.Join(old, "\n")+"\n"), 0o644))
+
+	s := openFile(t, dir)
+	// compact after every write
+	s.compactAt = 0
+	write := func(err error) {
+		t.Helper()
+		must(t, err)
+		s.compactAt = 0
+	}
+	for i := 0; i < 50; i++ {
+		write(s.SaveRoom(Room{ID: "r1", Data: json.RawMessage(fmt.Sprintf(`{"i":%d}`, i)), Saved: t0}))
+	}
+	write(s.SaveRoom(Room{ID: "r2", Data: json.RawMessage(`{}`), Saved: t0}))
+	write(s.DeleteRoom("r2"))
+	b, err := os.ReadFile(filepath.Join(dir, "store.log"))
+	must(t, err)
+	if lines := strings.Count(string(b), "\n"); lines != 2 {
+		t.Fatalf("store.log has %d lines after compaction, want 2:\n%s", lines, b)
+	}
+	users := []User{{ID: "u1", Name: "Ann", Created: t0, Seen: t0}}
+	rooms := map[string]string{"r1": `{"i":49}`}
+	events := map[string][]Event{"r1": {ev}}
+	check(t, s, users, rooms, []Result{res}, events)
+	must(t, s.Close())
+
+	s = openFile(t, dir)
+	check(t, s, users, rooms, []Result{res}, events)
+	if _, err := os.Stat(filepath.Join(dir, "store.log.tmp")); err == nil {
+		t.Fatal("compaction left its temporary file")
+	}
+}
+
+// TestFileTruncated cuts the last line of every log short, as a crash in
+// the middle of a write would, and reopens: the whole records are all
+// there and new ones are appended intact.
+func TestFileTruncated(t *testing.T) {
+	dir := t.TempDir()
+	s := openFile(t, dir)
+	u := User{ID: "u1", Name: "Ann", Created: t0, Seen: t0}
+	res := Result{Room: "r1", At: t0, Players: []string{"u1"}, Tricks: []int{1}, Scores: []int{-1}, Bid: 1}
+	ev := Event{Room: "r1", Action: "deal", At: t0}
+	must(t, s.PutUser(u))
+	must(t, s.AddResult(res))
+	must(t, s.AppendEvents(ev))
+	must(t, s.Close())
+	for _, name := range []string{"store.log", "results.log", filepath.Join("events", "r1.log")} {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND, 0)
+		must(t, err)
+		_, err = f.WriteString(`{"op":"user","user":{"id":"u2","na`)
+		must(t, err)
+		must(t, f.Close())
+	}
+
+	s = openFile(t, dir)
+	check(t, s, []User{u}, map[string]string{}, []Result{res}, map[string][]Event{"r1": {ev}})
+	if _, ok, _ := s.User("u2"); ok {
+		t.Fatal("user from a cut-short record")
+	}
+	res2, ev2 := res, ev
+	res2.Bid, ev2.Action = 2, "bid"
+	must(t, s.AddResult(res2))
+	must(t, s.AppendEvents(ev2))
+	must(t, s.Close())
+
+	s = openFile(t, dir)
+	check(t, s, []User{u}, map[string]string{}, []Result{res, res2}, map[string][]Event{"r1": {ev, ev2}})
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Memory keeps everything in maps; it is lost when the process exits.
+type Memory struct {
+	mu      sync.RWMutex
+	closed  bool
+	users   map[string]User
+	rooms   map[string]Room
+	results []Result
+	events  map[string][]Event
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:  make(map[string]User),
+		rooms:  make(map[string]Room),
+		events: make(map[string][]Event),
+	}
+}
+
+func (m *Memory) PutUser(u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.putUser(u)
+	return nil
+}
+
+// putUser keeps the first Created time a user was stored with.
+func (m *Memory) putUser(u User) {
+	if old, ok := m.users[u.ID]; ok && !old.Created.IsZero() {
+		u.Created = old.Created
+	}
+	m.users[u.ID] = u
+}
+
+func (m *Memory) User(id string) (User, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return User{}, false, ErrClosed
+	}
+	u, ok := m.users[id]
+	return u, ok, nil
+}
+
+func (m *Memory) SaveRoom(r Room) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.rooms[r.ID] = r
+	return nil
+}
+
+func (m *Memory) DeleteRoom(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	delete(m.rooms, id)
+	return nil
+}
+
+// Rooms returns the saved rooms ordered by ID.
+func (m *Memory) Rooms() ([]Room, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	out := make([]Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *Memory) AddResult(r Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.results = append(m.results, r)
+	return nil
+}
+
+func (m *Memory) Results(room string) ([]Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	var out []Result
+	for _, r := range m.results {
+		if room == "" || r.Room == room {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) AppendEvents(ev ...Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	for _, e := range ev {
+		m.events[e.Room] = append(m.events[e.Room], e)
+	}
+	return nil
+}
+
+func (m *Memory) Events(room string) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	return slices.Clone(m.events[room]), nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	return nil
+}
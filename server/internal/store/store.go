@@ -0,0 +1,71 @@
+// Package store keeps what should outlive the server process: users, room
+// snapshots, match results and per-room event logs. Memory is for tests
+// and single runs; File keeps everything in append-only logs under a
+// data directory. A database-backed store (DATABASE_URL) can implement the
+// same interface.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrClosed = errors.New("store: closed")
+
+// User is a player identity: an account or a guest.
+type User struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Guest   bool      `json:"guest,omitempty"`
+	Created time.Time `json:"created"`
+	Seen    time.Time `json:"seen"`
+}
+
+// Room is an opaque snapshot of one table; the hub owns its format.
+type Room struct {
+	ID    string          `json:"id"`
+	Data  json.RawMessage `json:"data"`
+	Saved time.Time       `json:"saved"`
+}
+
+// Result is the outcome of one finished hand.
+type Result struct {
+	Room     string    `json:"room"`
+	At       time.Time `json:"at"`
+	Players  []string  `json:"players"` // seat -> user id
+	Tricks   []int     `json:"tricks"`
+	Scores   []int     `json:"scores"` // running match score after the hand
+	Declarer int       `json:"declarer"`
+	Bid      int       `json:"bid"`
+	Trump    string    `json:"trump,omitempty"`
+	Knocked  bool      `json:"knocked,omitempty"`
+}
+
+// Event is one entry of a room's action log.
+type Event struct {
+	Room   string    `json:"room"`
+	Seat   int       `json:"seat"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Store is the persistence the hub needs. Implementations are safe for
+// concurrent use.
+type Store interface {
+	PutUser(u User) error
+	User(id string) (User, bool, error)
+
+	SaveRoom(r Room) error
+	DeleteRoom(id string) error
+	Rooms() ([]Room, error)
+
+	AddResult(r Result) error
+	Results(room string) ([]Result, error) // "" lists every room's
+
+	AppendEvents(ev ...Event) error
+	Events(room string) ([]Event, error)
+
+	Close() error
+}
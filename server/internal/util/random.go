@@ -0,0 +1,25 @@
+package util
+
+import (
+	crand "crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet leaves out characters that are easy to confuse when read
+// aloud or typed from a screenshot (0/O, 1/I/L).
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// Code returns a random human-friendly code of n characters.
+func Code(n int) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range b {
+		v, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			b[i] = codeAlphabet[0]
+			continue
+		}
+		b[i] = codeAlphabet[v.Int64()]
+	}
+	return string(b)
+}
@@ -0,0 +1,127 @@
+package ws
+
+// Abandonment: what a running hand does when a player leaves, is kicked or
+// disconnects, per Rules.OnLeave. Between hands the seat is simply freed.
+
+const (
+	leaveBot  = "bot"  // a bot plays the seat to the end of the hand
+	leaveVoid = "void" // the hand is thrown in and redealt without the leaver, unless draining
+	leaveLose = "lose" // the hand ends, scored as lost for the leaver
+)
+
+// abandonSeat applies the room's OnLeave rule to seat and returns the
+// outcome, "" if the seat was just freed. With hold (a disconnect) a
+// stand-in bot keeps the seat until its player rejoins; otherwise the bot
+// leaves when the hand ends. Runs on the room's goroutine.
+func (h *Hub) abandonSeat(room *Room, seat int, hold bool) string {
+	if room.Phase == "" || !othersSeated(room, seat) {
+		vacateSeat(room, seat)
+		return ""
+	}
+	switch room.Rules.OnLeave {
+	case leaveVoid:
+		vacateSeat(room, seat)
+		resetHand(room)
+		if h.draining.Load() {
+			// no new hands while draining: the table waits between hands
+			room.Phase = ""
+			logAction(room, seat, "left", "hand thrown in")
+			return leaveVoid
+		}
+		logAction(room, seat, "left", "hand redealt")
+		return leaveVoid
+	case leaveLose:
+		h.forfeitHand(room, seat, "left the table")
+		vacateSeat(room, seat)
+		return leaveLose
+	default:
+		leaver := room.PlayerIDs[seat]
+		h.standIn(room, seat)
+		if !hold {
+			room.leavers[seat] = true
+		}
+		if room.Host == leaver {
+			room.Host = ""
+			passHost(room, seat)
+		}
+		logAction(room, seat, "left", "bot plays on")
+		return leaveBot
+	}
+}
+
+// standIn puts a bot in seat to play for its absent player, who keeps the
+// seat. A bot already there, kicked by the host, is stopped first. Runs
+// on the room's goroutine.
+func (h *Hub) standIn(room *Room, seat int) {
+	stopBot(room.Sessions[seat])
+	bs := h.newBotSession(room.Rules.TakeoverBot)
+	bs.sit(room.ID, seat)
+	room.Sessions[seat] = bs
+}
+
+// takeBack gives seat back to s, its player, from the bot or the restart
+// that held it. Runs on the room's goroutine.
+func (h *Hub) takeBack(s *Session, room *Room, seat int) {
+	oldID := room.PlayerIDs[seat]
+	stopBot(room.Sessions[seat])
+	delete(room.leavers, seat)
+	room.Sessions[seat] = s
+	room.PlayerIDs[seat] = s.id
+	s.sit(room.ID, seat)
+	delete(room.watchers, s)
+	if room.Host == "" {
+		room.Host = s.id
+	}
+	if room.Phase != "" {
+		// after a restart, bots hold the seats of those still away
+		for other := range room.PlayerIDs {
+			if reserved(room, other) {
+				h.standIn(room, other)
+			}
+		}
+	}
+	h.namesMu.Lock()
+	if _, ok := h.names[s.id]; !ok && h.names[oldID] != "" {
+		h.names[s.id] = h.names[oldID]
+	}
+	h.namesMu.Unlock()
+	h.broadcastRoom(room, "seat_bot", map[string]any{"room": room.ID, "seat": seat, "bot": false})
+	h.broadcastState(room)
+}
+
+// othersSeated reports whether a person other than seat's player is
+// still at the table; without one there is nobody to play on for.
+func othersSeated(room *Room, seat int) bool {
+	for s, pid := range room.PlayerIDs {
+		if s != seat && pid != "" && !isBotSeat(room, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseLeavers frees the seats bots were finishing for players who left.
+// Runs on the room's goroutine.
+func releaseLeavers(room *Room) {
+	for s := range room.leavers {
+		vacateSeat(room, s)
+	}
+	room.leavers = make(map[int]bool)
+}
+
+// announceLeave tells the table that seat's player went and what became of
+// the hand.
+func (h *Hub) announceLeave(room *Room, seat int, outcome string, kicked bool) {
+	if outcome == leaveBot {
+		h.broadcastRoom(room, "seat_bot", map[string]any{"room": room.ID, "seat": seat, "bot": true})
+	}
+	if outcome == "" {
+		outcome = "none"
+	}
+	h.broadcastRoom(room, "player_left", map[string]any{
+		"room":    room.ID,
+		"seat":    seat,
+		"outcome": outcome,
+		"kicked":  kicked,
+	})
+}
@@ -0,0 +1,124 @@
+package ws
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestLeaveLose has a player walk out of a running hand under the lose
+// policy: the hand is booked as their loss, with their id and penalised
+// score, before their seat and score are cleared.
+func TestLeaveLose(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("lose", 3, "p-0", 7)
+	room.Rules.OnLeave = leaveLose
+	players := seatHeadless(h, room, "p")
+	room.Scores[0], room.Scores[1], room.Scores[2] = -3, 2, -1
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+
+	var penalty int
+	room.do(func() {
+		h.startHand(room)
+		penalty = handDelta(1, 1, max(room.BestBid, 1), 0, false, room.Trump, room.RoundDouble)
+		h.leaveRoom(players[1].sess, room, false)
+	})
+
+	var scores map[int]int
+	var ids []string
+	room.do(func() {
+		scores, ids = maps.Clone(room.Scores), slices.Clone(room.PlayerIDs)
+	})
+	if _, ok := scores[1]; ok || scores[0] != -3 || scores[2] != -1 {
+		t.Fatalf("scores after the leave %v", scores)
+	}
+	if ids[1] != "" {
+		t.Fatalf("seat 1 still held by %q", ids[1])
+	}
+
+	results, err := h.store.Results(room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d results stored, want 1", len(results))
+	}
+	res := results[0]
+	if want := []string{"p-0", "p-1", "p-2"}; !slices.Equal(res.Players, want) {
+		t.Fatalf("result players %q, want %q", res.Players, want)
+	}
+	if want := []int{-3, 2 + penalty, -1}; !slices.Equal(res.Scores, want) {
+		t.Fatalf("result scores %v, want %v", res.Scores, want)
+	}
+}
+
+// TestJoinMidHand has a player leave under the void policy, which redeals
+// without them; someone joining before the hand ends only gets to watch.
+func TestJoinMidHand(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("void", 3, "p-0", 7)
+	room.Rules.OnLeave = leaveVoid
+	players := seatHeadless(h, room, "p")
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+	room.do(func() {
+		h.startHand(room)
+		h.leaveRoom(players[2].sess, room, false)
+	})
+
+	c := headless(h, "late")
+	sendRaw(h, c, "join_table", map[string]any{"room": room.ID})
+	if msg := expect(t, c, "error"); msg["msg"] != "cannot join during a hand" {
+		t.Fatalf("error %v", msg)
+	}
+	var phase string
+	var ids []string
+	var watching bool
+	room.do(func() {
+		phase, ids, watching = room.Phase, slices.Clone(room.PlayerIDs), room.watchers[c.sess]
+	})
+	if phase == "" {
+		t.Fatal("no hand running after the redeal")
+	}
+	if slices.Contains(ids, "late") || c.sess.seatIn(room.ID) >= 0 {
+		t.Fatalf("seated mid-hand: %q", ids)
+	}
+	if !watching {
+		t.Fatal("not watching")
+	}
+	expect(t, c, "state")
+}
+
+// TestKickBotMidHand kicks a bot out of a running hand under the bot
+// policy: a fresh bot plays on and the kicked one stops.
+func TestKickBotMidHand(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("kick", 3, "p-0", 7)
+	seatHeadless(h, room, "p")
+	host := headless(h, "p-0")
+	host.sess.sit(room.ID, 0)
+	room.Sessions[0] = host.sess
+	old := h.newBotSession("heuristic")
+	old.sit(room.ID, 2)
+	room.PlayerIDs[2], room.Sessions[2] = old.id, old
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+	room.do(func() { h.startHand(room) })
+
+	sendRaw(h, host, "kick", map[string]any{"room": room.ID, "seat": 2})
+	var now *Session
+	room.do(func() { now = room.Sessions[2] })
+	if now == old || now == nil || now.bot == nil {
+		t.Fatalf("seat 2 held by %+v", now)
+	}
+	select {
+	case <-old.stop:
+	default:
+		t.Fatal("kicked bot still running")
+	}
+}
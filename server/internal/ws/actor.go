@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Room actors: every room has its own goroutine that runs whatever touches
+// the room, one thing at a time: client actions, bot moves, timeouts,
+// snapshots, janitor checks. The hub only finds the room and hands the
+// work over, so tables never wait on each other. roomsMu guards nothing but
+// the map of rooms; the rest of the hub reads a room through its summary.
+
+// roomSummary is what the hub may know about a room without asking its
+// goroutine. The room republishes it after every call.
+type roomSummary struct {
+	info          roomInfo
+	phase         string
+	players       []string // seat -> player id, as in Room.PlayerIDs
+	private       bool
+	host          string
+	inviteCode    string
+	inviteExpires time.Time
+}
+
+// addRoom registers r and starts its goroutine.
+func (h *Hub) addRoom(r *Room) {
+	r.publish()
+	h.roomsMu.Lock()
+	h.rooms[r.ID] = r
+	h.roomsMu.Unlock()
+	go r.run()
+}
+
+// room returns the room with id, nil if there is none.
+func (h *Hub) room(id string) *Room {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	return h.rooms[id]
+}
+
+// allRooms returns every room at this moment.
+func (h *Hub) allRooms() []*Room {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	out := make([]*Room, 0, len(h.rooms))
+	for _, r := range h.rooms {
+		out = append(out, r)
+	}
+	return out
+}
+
+// roomByCode finds the room holding an invite code; the room still checks
+// it is valid.
+func (h *Hub) roomByCode(code string) *Room {
+	for _, r := range h.allRooms() {
+		if c := r.summary.Load().inviteCode; c != "" && strings.EqualFold(c, code) {
+			return r
+		}
+	}
+	return nil
+}
+
+// removeRoom deletes r: its bots, timers and memberships go and its
+// goroutine ends after the current call. Runs on r's goroutine.
+func (h *Hub) removeRoom(r *Room) {
+	h.roomsMu.Lock()
+	if h.rooms[r.ID] == r {
+		delete(h.rooms, r.ID)
+	}
+	h.roomsMu.Unlock()
+	stopBots(r)
+	stopTurnTimer(r)
+	stopClock(r)
+	forgetRoom(r)
+	r.closed = true
+}
+
+func (r *Room) run() {
+	for call := range r.inbox {
+		call()
+		if r.closed {
+			close(r.quit)
+			return
+		}
+		r.publish()
+	}
+}
+
+// do runs fn on r's goroutine and waits for it. It reports false, without
+// running fn, if r has been removed. A panic in fn is raised again in the
+// caller, and the room carries on.
+func (r *Room) do(fn func()) bool {
+	var p any
+	done := make(chan struct{})
+	call := func() {
+		defer close(done)
+		defer func() { p = recover() }()
+		fn()
+	}
+	select {
+	case r.inbox <- call:
+	case <-r.quit:
+		return false
+	}
+	<-done
+	if p != nil {
+		panic(p)
+	}
+	return true
+}
+
+// publish refreshes r's summary. Runs on r's goroutine.
+func (r *Room) publish() {
+	occ := 0
+	for _, pid := range r.PlayerIDs {
+		if pid != "" {
+			occ++
+		}
+	}
+	r.summary.Store(&roomSummary{
+		info:          roomInfo{ID: r.ID, Seats: r.Seats, Occupied: occ, Started: r.Started, Password: r.passHash != nil, Locked: r.Locked},
+		phase:         r.Phase,
+		players:       slices.Clone(r.PlayerIDs),
+		private:       r.Private,
+		host:          r.Host,
+		inviteCode:    r.InviteCode,
+		inviteExpires: r.InviteExpires,
+	})
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Maintenance: operators can broadcast announcements to every client and
+// put the hub in drain mode, where no table or hand starts but running
+// hands play out. Both sit behind /admin/ with a bearer token.
+
+const (
+	drainNoTables = "the server is in maintenance: no new tables for now"
+	drainNoHands  = "the server is in maintenance: no new hands for now"
+)
+
+// SetDraining turns drain mode on or off.
+func (h *Hub) SetDraining(on bool) {
+	h.draining.Store(on)
+	h.broadcastAll("maintenance", map[string]any{"draining": on})
+}
+
+// Draining reports whether the hub refuses new tables and hands.
+func (h *Hub) Draining() bool {
+	return h.draining.Load()
+}
+
+// Announce sends msg to every connected client. With in > 0 it carries a
+// countdown to the moment it announces, e.g. a restart.
+func (h *Hub) Announce(msg string, in time.Duration) {
+	m := map[string]any{"msg": msg, "at": time.Now().UnixMilli()}
+	if in > 0 {
+		m["deadline"] = time.Now().Add(in).UnixMilli()
+		m["seconds"] = int(in.Round(time.Second).Seconds())
+	}
+	h.broadcastAll("announcement", m)
+}
+
+// AdminHandler serves the operator endpoints, authorised by
+// "Authorization: Bearer <token>":
+//
+//	POST /admin/announce {"msg": "...", "seconds": 600}
+//	POST /admin/drain    {"draining": true}
+//
+// With an empty token every request is refused.
+func (h *Hub) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/announce", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Msg     string `json:"msg"`
+			Seconds int    `json:"seconds"`
+		}
+		if !decodeAdmin(w, r, &req) {
+			return
+		}
+		msg := strings.TrimSpace(req.Msg)
+		if msg == "" {
+			http.Error(w, "msg is required", http.StatusBadRequest)
+			return
+		}
+		h.Announce(msg, time.Duration(max(req.Seconds, 0))*time.Second)
+		h.clientsMu.RLock()
+		n := len(h.clients)
+		h.clientsMu.RUnlock()
+		writeJSON(w, map[string]any{"sent": n})
+	})
+	mux.HandleFunc("/admin/drain", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Draining bool `json:"draining"`
+		}
+		if !decodeAdmin(w, r, &req) {
+			return
+		}
+		h.SetDraining(req.Draining)
+		writeJSON(w, map[string]any{"draining": h.Draining()})
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// decodeAdmin reads a POSTed JSON body into v, answering the error itself.
+func decodeAdmin(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(v); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/auth"
+)
+
+// Auth modes: what ServeWS does with a connection that has no valid token.
+const (
+	AuthOff      = "off"      // tokens are ignored; everyone plays as a guest
+	AuthOptional = "optional" // a valid token names the player, others are guests
+	AuthRequired = "required" // connections without a valid token are refused
+)
+
+// AuthConfig holds the HS256 secret and the mode. Guests turns on guest
+// tokens, which any visitor can get, so in required mode they make every
+// visitor a signed-in player. GuestTTL is how long guest tokens last (30
+// days if zero).
+type AuthConfig struct {
+	Mode     string
+	Secret   []byte
+	Guests   bool
+	GuestTTL time.Duration
+}
+
+// SetAuth configures connection authentication. Call before serving.
+func (h *Hub) SetAuth(cfg AuthConfig) {
+	h.auth = cfg
+}
+
+// tokenSubprotocol marks a token sent as a websocket subprotocol, for
+// browsers that can't set headers: new WebSocket(url, ["jwt", token]).
+const tokenSubprotocol = "jwt"
+
+// tokenFrom finds the token in the "token" query parameter or in the
+// subprotocol after "jwt".
+func tokenFrom(r *http.Request) string {
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t
+	}
+	var protos []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			protos = append(protos, strings.TrimSpace(p))
+		}
+	}
+	for i := 0; i+1 < len(protos); i++ {
+		if protos[i] == tokenSubprotocol {
+			return protos[i+1]
+		}
+	}
+	return ""
+}
+
+// authenticate returns the caller's claims; ok is false for guests. err is
+// set when the connection must be refused.
+func (h *Hub) authenticate(r *http.Request) (c auth.Claims, ok bool, err error) {
+	if h.auth.Mode == "" || h.auth.Mode == AuthOff {
+		return c, false, nil
+	}
+	tok := tokenFrom(r)
+	if tok == "" {
+		if h.auth.Mode == AuthRequired {
+			return c, false, auth.ErrMalformed
+		}
+		return c, false, nil
+	}
+	c, err = auth.Verify(h.auth.Secret, tok, time.Now())
+	if err != nil {
+		if h.auth.Mode == AuthRequired {
+			return c, false, err
+		}
+		// optional mode: a bad token plays as a guest
+		return auth.Claims{}, false, nil
+	}
+	return c, true, nil
+}
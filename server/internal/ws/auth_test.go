@@ -0,0 +1,34 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenFrom(t *testing.T) {
+	for _, tc := range []struct {
+		name, query string
+		protocols   []string // one Sec-WebSocket-Protocol header each
+		want        string
+	}{
+		{"none", "", nil, ""},
+		{"query", "?token=abc", nil, "abc"},
+		{"subprotocol", "", []string{"jwt, abc"}, "abc"},
+		{"subprotocol among others", "", []string{"chat, jwt,abc , v2"}, "abc"},
+		{"subprotocol over headers", "", []string{"chat", "jwt", "abc"}, "abc"},
+		{"query wins", "?token=q", []string{"jwt, p"}, "q"},
+		{"jwt without a token", "", []string{"chat, jwt"}, ""},
+		{"no jwt marker", "", []string{"abc"}, ""},
+		{"empty query", "?token=", []string{"jwt, p"}, "p"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws"+tc.query, nil)
+			for _, p := range tc.protocols {
+				r.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			if got := tokenFrom(r); got != tc.want {
+				t.Fatalf("tokenFrom = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
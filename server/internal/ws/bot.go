@@ -0,0 +1,386 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultBotDelay = 700 * time.Millisecond
+
+// Bot decides a seat's next action from the same "state" message a human
+// client would receive for that seat, so it never sees other hands.
+type Bot interface {
+	// Act returns the action to send, or ok=false when there is nothing to do.
+	Act(v *seatView) (a botAction, ok bool)
+}
+
+type botAction struct {
+	T   string
+	M   map[string]any
+	Why string // short rationale, e.g. "must follow hearts"
+}
+
+// seatView mirrors the fields of the state message that bots use.
+type seatView struct {
+	Room        string     `json:"room"`
+	Seat        int        `json:"seat"`
+	Phase       string     `json:"phase"`
+	Actor       int        `json:"actor"`
+	Dealer      int        `json:"dealer"`
+	FirstBidder int        `json:"firstBidder"`
+	BestBid     int        `json:"bestBid"`
+	BestBy      int        `json:"bestBy"`
+	Passed      []int      `json:"passed"`
+	RoundDouble bool       `json:"roundDouble"`
+	Stayed      []int      `json:"stayed"`
+	CutPeek     *viewCard  `json:"cutPeek"`
+	Turn        int        `json:"turn"`
+	Trump       string     `json:"trump"`
+	Lead        string     `json:"lead"`
+	Trick       []viewCard `json:"trick"`
+	Tricks      []int      `json:"tricks"`
+	LastTrick   *struct {
+		Cards  []viewCard `json:"cards"`
+		Winner int        `json:"winner"`
+	} `json:"lastTrick"`
+	You         []Card   `json:"you"`
+	Counts      []int    `json:"counts"`
+	Names       []string `json:"names"`
+	Talon       int      `json:"talon"`
+	Swamp       int      `json:"swamp"`
+	ExchangeMax int      `json:"exchangeMax"`
+	HandOver    bool     `json:"handOver"`
+}
+
+type viewCard struct {
+	Suit string `json:"suit"`
+	Rank string `json:"rank"`
+	By   int    `json:"by"`
+}
+
+func (vc viewCard) card() Card { return Card{Suit: vc.Suit, Rank: vc.Rank} }
+
+func (v *seatView) passed(s int) bool { return containsInt(v.Passed, s) }
+func (v *seatView) stayed(s int) bool { return containsInt(v.Stayed, s) }
+
+func containsInt(list []int, x int) bool {
+	for _, v := range list {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+// newBot builds a bot by kind; unknown kinds get the heuristic bot.
+// "ismcts" takes an optional strength suffix: "ismcts:500" (playouts) or
+// "ismcts:250ms" (time per decision).
+func newBot(kind string) Bot {
+	return newSeededBot(kind, time.Now().UnixNano())
+}
+
+// newSeededBot is newBot with a fixed seed for bots that use randomness.
+func newSeededBot(kind string, seed int64) Bot {
+	name, spec, _ := strings.Cut(kind, ":")
+	switch name {
+	case "ismcts":
+		return newISMCTSBot(spec, seed)
+	default:
+		return &heuristicBot{}
+	}
+}
+
+// ----------------------------- Bot clients -----------------------------
+
+// newBotSession makes a server-side player: a session whose only
+// connection is the bot's loop.
+func (h *Hub) newBotSession(kind string) *Session {
+	s := newSession("bot-" + randID())
+	s.bot = newBot(kind)
+	s.botKind = kind
+	s.stop = make(chan struct{})
+	c := &Client{hub: h, send: make(chan []byte, 64)}
+	s.attach(c)
+	go c.botLoop()
+	return s
+}
+
+// botLoop is the bot's read side: it consumes the messages the hub would
+// write to a socket and answers the newest state.
+func (c *Client) botLoop() {
+	for {
+		var v *seatView
+		select {
+		case <-c.sess.stop:
+			return
+		case msg := <-c.send:
+			v = parseState(msg)
+		}
+	drain:
+		for {
+			select {
+			case msg := <-c.send:
+				if nv := parseState(msg); nv != nil {
+					v = nv
+				}
+			default:
+				break drain
+			}
+		}
+		if v == nil {
+			continue
+		}
+		a, ok := c.sess.bot.Act(v)
+		if !ok {
+			continue
+		}
+		if c.hub.botDelay > 0 {
+			select {
+			case <-c.sess.stop:
+				return
+			case <-time.After(c.hub.botDelay):
+			}
+		}
+		a.M["room"] = v.Room
+		a.M["seat"] = v.Seat
+		data, _ := json.Marshal(map[string]any{"t": a.T, "m": a.M})
+		c.hub.handleRaw(c, data)
+	}
+}
+
+func parseState(msg []byte) *seatView {
+	var env struct {
+		T string   `json:"t"`
+		M seatView `json:"m"`
+	}
+	if err := json.Unmarshal(msg, &env); err != nil || env.T != "state" {
+		return nil
+	}
+	return &env.M
+}
+
+// stopBot ends a bot session's loop. Runs on the room's goroutine.
+func stopBot(s *Session) {
+	if s == nil || s.bot == nil {
+		return
+	}
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+func stopBots(room *Room) {
+	for _, s := range room.Sessions {
+		stopBot(s)
+	}
+}
+
+func isBotSeat(room *Room, s int) bool {
+	return room.Sessions[s] != nil && room.Sessions[s].bot != nil
+}
+
+// humansSeated reports whether any seat is held by a person.
+func humansSeated(room *Room) bool {
+	for s, pid := range room.PlayerIDs {
+		if pid != "" && !isBotSeat(room, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------- Heuristic bot -----------------------------
+
+// heuristicBot plays by simple card-counting rules of thumb. It keeps no
+// memory between states.
+type heuristicBot struct{}
+
+func (b *heuristicBot) Act(v *seatView) (botAction, bool) {
+	switch v.Phase {
+	case "start":
+		if v.Seat == v.FirstBidder {
+			return botAction{T: "start_choice", M: map[string]any{"choice": "cut"}, Why: "cut and see the cards"}, true
+		}
+	case "cut":
+		if v.Seat == v.FirstBidder {
+			return botAction{T: "cut_proceed", M: map[string]any{}, Why: "deal"}, true
+		}
+	case "bidding":
+		if v.Seat == v.Actor && !v.passed(v.Seat) {
+			return heuristicBid(v), true
+		}
+	case "pick_trump":
+		if v.Seat == v.BestBy {
+			suit, est := bestTrump(v.You)
+			return botAction{T: "pick_trump", M: map[string]any{"trump": suit},
+				Why: fmt.Sprintf("%s is your strongest suit (about %.1f tricks)", suit, est)}, true
+		}
+	case "exchange":
+		if v.Seat == v.Actor {
+			return heuristicExchange(v), true
+		}
+	case "play":
+		if v.Seat == v.Turn && !v.stayed(v.Seat) && len(v.You) > 0 {
+			return heuristicPlay(v), true
+		}
+	}
+	return botAction{}, false
+}
+
+// trumpStrength estimates how many tricks hand takes with trump as trumps.
+func trumpStrength(hand []Card, trump string) float64 {
+	est, trumps := 0.0, 0
+	for _, c := range hand {
+		if isTrump(c, trump) {
+			trumps++
+			switch v := cardValue(c); {
+			case v == rankValue["ace"]:
+				est += 1
+			case v == weliValue:
+				est += 0.9
+			case v == rankValue["king"]:
+				est += 0.7
+			case v == rankValue["ober"]:
+				est += 0.5
+			case v == rankValue["unter"]:
+				est += 0.35
+			default:
+				est += 0.2
+			}
+			continue
+		}
+		if strings.EqualFold(c.Rank, "ace") {
+			est += 0.75
+		}
+	}
+	if trumps > 2 {
+		est += 0.3 * float64(trumps-2)
+	}
+	if est > 5 {
+		est = 5
+	}
+	return est
+}
+
+func bestTrump(hand []Card) (string, float64) {
+	best, bestEst := suits[0], -1.0
+	for _, s := range suits {
+		if e := trumpStrength(hand, s); e > bestEst {
+			best, bestEst = s, e
+		}
+	}
+	return best, bestEst
+}
+
+func heuristicBid(v *seatView) botAction {
+	suit, est := bestTrump(v.You)
+	target := int(est + 0.25)
+	othersIn := false
+	for s := range v.Counts {
+		if s != v.Seat && v.Counts[s] > 0 && !v.passed(s) {
+			othersIn = true
+		}
+	}
+	if !othersIn && v.BestBy == -1 {
+		// everyone else passed without a bid; someone has to play
+		return botAction{T: "bid", M: map[string]any{"bid": 1}, Why: "everyone passed; take it at 1"}
+	}
+	next := v.BestBid + 1
+	if next <= 5 && next <= target {
+		return botAction{T: "bid", M: map[string]any{"bid": next},
+			Why: fmt.Sprintf("about %.1f tricks with %s as trump", est, suit)}
+	}
+	return botAction{T: "pass", M: map[string]any{},
+		Why: fmt.Sprintf("only about %.1f tricks; %d is too many", est, next)}
+}
+
+func heuristicExchange(v *seatView) botAction {
+	declarer := v.Seat == v.BestBy
+	if !declarer && v.Trump != "clubs" && trumpStrength(v.You, v.Trump) < 0.8 {
+		return botAction{T: "stay_home", M: map[string]any{}, Why: "too weak to take a trick; stay home"}
+	}
+	var discard []any
+	for _, c := range byStrength(v.You, v.Trump) {
+		if len(discard) >= v.ExchangeMax {
+			break
+		}
+		if isTrump(c, v.Trump) || strings.EqualFold(c.Rank, "ace") {
+			continue
+		}
+		discard = append(discard, map[string]any{"Suit": c.Suit, "Rank": c.Rank})
+	}
+	if len(discard) == 0 {
+		return botAction{T: "exchange_done", M: map[string]any{}, Why: "only trumps and aces; keep the hand"}
+	}
+	return botAction{T: "exchange", M: map[string]any{"cards": discard},
+		Why: fmt.Sprintf("swap %d weak side cards", len(discard))}
+}
+
+func heuristicPlay(v *seatView) botAction {
+	legal := byStrength(legalPlays(v.You, v.Lead, v.Trump), v.Trump)
+	play := func(c Card, why string) botAction {
+		return botAction{T: "move", M: map[string]any{
+			"type": "play_card",
+			"card": map[string]any{"Suit": c.Suit, "Rank": c.Rank},
+		}, Why: why}
+	}
+	if len(v.Trick) == 0 {
+		strongest := legal[len(legal)-1]
+		if v.Seat == v.BestBy && isTrump(strongest, v.Trump) {
+			return play(strongest, "lead your highest trump to pull trumps")
+		}
+		for i := len(legal) - 1; i >= 0; i-- {
+			if !isTrump(legal[i], v.Trump) && strings.EqualFold(legal[i].Rank, "ace") {
+				return play(legal[i], "cash a side ace")
+			}
+		}
+		return play(legal[0], "lead low")
+	}
+	best := v.Trick[0].card()
+	for _, tc := range v.Trick[1:] {
+		if beats(tc.card(), best, v.Lead, v.Trump) {
+			best = tc.card()
+		}
+	}
+	why := ""
+	if effSuit(legal[0], v.Trump) == v.Lead {
+		why = "must follow " + v.Lead
+	} else if isTrump(legal[0], v.Trump) {
+		why = "no " + v.Lead + ", must trump"
+	}
+	for _, c := range legal {
+		if beats(c, best, v.Lead, v.Trump) {
+			return play(c, joinWhy(why, "lowest card that wins"))
+		}
+	}
+	return play(legal[0], joinWhy(why, "can't win; throw the lowest"))
+}
+
+func joinWhy(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
+
+// byStrength returns a copy of cards ordered weakest first, trumps last.
+func byStrength(cards []Card, trump string) []Card {
+	out := append([]Card(nil), cards...)
+	key := func(c Card) int {
+		k := cardValue(c)
+		if isTrump(c, trump) {
+			k += 100
+		}
+		return k
+	}
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && key(out[j]) < key(out[j-1]); j-- {
+			out[j], out[j-1] = out[j-1], out[j]
+		}
+	}
+	return out
+}
@@ -0,0 +1,702 @@
+package ws
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ismctsBot plays by information-set Monte Carlo search: for every decision
+// it samples deals of the cards its seat cannot see and evaluates its
+// options by playing them out. It only ever reads its own seat's state
+// messages (own hand, cards played, who stayed home, the cut peek if it
+// cut), so it knows exactly what a human in that seat would know.
+type ismctsBot struct {
+	limits searchLimits // per decision
+	rng    *rand.Rand
+
+	mem handMemory
+}
+
+// handMemory is what the seat has seen so far this hand.
+type handMemory struct {
+	lastPhase string
+	played    map[Card]bool
+	voids     map[int]map[string]bool // seat -> suits it showed out of
+	discards  []Card                  // our own exchange discards
+	lastSwamp int
+	swampUsed bool  // someone drew from the swamp; our discards may be back
+	peek      *Card // the card we saw when we cut: the bottom of the talon
+}
+
+const (
+	defaultISMCTSIterations = 1500
+	defaultISMCTSBudget     = time.Second
+	ismctsExplore           = 0.7
+)
+
+// newISMCTSBot parses a strength spec: "" for the defaults, an iteration
+// count such as "500", or a time budget such as "250ms".
+func newISMCTSBot(spec string, seed int64) *ismctsBot {
+	b := &ismctsBot{
+		limits: searchLimits{iterations: defaultISMCTSIterations, budget: defaultISMCTSBudget},
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n > 0 {
+		b.limits = searchLimits{iterations: n}
+	} else if d, err := time.ParseDuration(spec); err == nil && d > 0 {
+		b.limits = searchLimits{budget: d}
+	}
+	return b
+}
+
+var phaseOrder = map[string]int{"start": 0, "cut": 1, "bidding": 2, "pick_trump": 3, "exchange": 4, "play": 5, "": 6}
+
+func normCard(c Card) Card {
+	return Card{Suit: strings.ToLower(c.Suit), Rank: strings.ToLower(c.Rank)}
+}
+
+func (b *ismctsBot) Act(v *seatView) (botAction, bool) {
+	b.observe(v)
+	switch v.Phase {
+	case "start":
+		if v.Seat == v.FirstBidder {
+			return botAction{T: "start_choice", M: map[string]any{"choice": "cut"}, Why: "cut and see the cards"}, true
+		}
+	case "cut":
+		if v.Seat == v.FirstBidder {
+			return botAction{T: "cut_proceed", M: map[string]any{}, Why: "deal"}, true
+		}
+	case "bidding":
+		if v.Seat == v.Actor && !v.passed(v.Seat) {
+			return b.bid(v), true
+		}
+	case "pick_trump":
+		if v.Seat == v.BestBy {
+			return b.pickTrump(v), true
+		}
+	case "exchange":
+		if v.Seat == v.Actor {
+			a := b.exchange(v)
+			if a.T == "exchange" {
+				for _, x := range a.M["cards"].([]any) {
+					cm := x.(map[string]any)
+					b.mem.discards = append(b.mem.discards, normCard(Card{Suit: cm["Suit"].(string), Rank: cm["Rank"].(string)}))
+				}
+			}
+			return a, true
+		}
+	case "play":
+		if v.Seat == v.Turn && !v.stayed(v.Seat) && len(v.You) > 0 {
+			return b.play(v), true
+		}
+	}
+	return botAction{}, false
+}
+
+// observe folds a state message into the hand memory.
+func (b *ismctsBot) observe(v *seatView) {
+	if phaseOrder[v.Phase] < phaseOrder[b.mem.lastPhase] || b.mem.played == nil {
+		b.mem = handMemory{
+			played: make(map[Card]bool),
+			voids:  make(map[int]map[string]bool),
+		}
+	}
+	b.mem.lastPhase = v.Phase
+	if v.CutPeek != nil && !isWeli(v.CutPeek.card()) {
+		c := normCard(v.CutPeek.card())
+		b.mem.peek = &c
+	}
+	if v.Phase == "exchange" {
+		if v.Talon == 0 && v.Swamp < b.mem.lastSwamp {
+			b.mem.swampUsed = true
+		}
+		b.mem.lastSwamp = v.Swamp
+	}
+	if v.LastTrick != nil {
+		b.observeTrick(v.LastTrick.Cards, v.Trump)
+	}
+	b.observeTrick(v.Trick, v.Trump)
+}
+
+func (b *ismctsBot) observeTrick(trick []viewCard, trump string) {
+	if len(trick) == 0 {
+		return
+	}
+	lead := effSuit(trick[0].card(), trump)
+	for i, tc := range trick {
+		c := normCard(tc.card())
+		b.mem.played[c] = true
+		if i == 0 || effSuit(c, trump) == lead {
+			continue
+		}
+		// showed out of the lead suit, and out of trumps too if not trumping
+		b.markVoid(tc.By, lead)
+		if !isTrump(c, trump) {
+			b.markVoid(tc.By, trump)
+		}
+	}
+}
+
+func (b *ismctsBot) markVoid(seat int, suit string) {
+	if b.mem.voids[seat] == nil {
+		b.mem.voids[seat] = make(map[string]bool)
+	}
+	b.mem.voids[seat][suit] = true
+}
+
+// searchLimits caps a search by playouts, time or both.
+type searchLimits struct {
+	iterations int           // 0 = no limit
+	budget     time.Duration // 0 = no limit
+}
+
+// more reports whether a search that began at start and has done i
+// playouts may continue. The first playout always runs.
+func (l searchLimits) more(start time.Time, i int) bool {
+	if i == 0 {
+		return true
+	}
+	if l.iterations > 0 && i >= l.iterations {
+		return false
+	}
+	if l.budget > 0 && time.Since(start) >= l.budget {
+		return false
+	}
+	return l.iterations > 0 || l.budget > 0
+}
+
+// split shares the limits between parts evaluations.
+func (l searchLimits) split(parts int) searchLimits {
+	if l.iterations > 0 {
+		l.iterations = max(l.iterations/parts, 1)
+	}
+	l.budget /= time.Duration(parts)
+	return l
+}
+
+// ----------------------------- Sampling -----------------------------
+
+// unseen returns the cards whose location the seat does not know: the
+// deck minus its own hand, everything played, its own discards (until the
+// swamp is drawn from) and the cut peek while it is still in the talon. A
+// Weli peek needs no entry of its own: the cutter holds it.
+func (b *ismctsBot) unseen(v *seatView) []Card {
+	known := make(map[Card]bool)
+	for _, c := range v.You {
+		known[normCard(c)] = true
+	}
+	for c := range b.mem.played {
+		known[c] = true
+	}
+	if p := b.peekInTalon(v); p != nil {
+		known[*p] = true
+	}
+	if !b.mem.swampUsed {
+		for _, c := range b.mem.discards {
+			known[c] = true
+		}
+	}
+	var pool []Card
+	for _, c := range buildMulatschakDeck() {
+		if !known[c] {
+			pool = append(pool, c)
+		}
+	}
+	return pool
+}
+
+// peekInTalon is the card we saw when we cut if it is still the bottom of
+// the talon, nil if we did not cut or it has been drawn.
+func (b *ismctsBot) peekInTalon(v *seatView) *Card {
+	if b.mem.peek == nil || v.Talon == 0 {
+		return nil
+	}
+	for _, c := range v.You {
+		if normCard(c) == *b.mem.peek {
+			return nil
+		}
+	}
+	return b.mem.peek
+}
+
+// sampleHands deals need[s] cards from pool to each seat, keeping the
+// seats' known voids when it can. It returns the hands and the leftovers.
+func (b *ismctsBot) sampleHands(pool []Card, need []int, trump string) ([][]Card, []Card) {
+	deck := append([]Card(nil), pool...)
+	for attempt := 0; ; attempt++ {
+		b.rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+		hands := make([][]Card, len(need))
+		var rest []Card
+		strict := attempt < 20
+		ok := true
+		for _, c := range deck {
+			placed := false
+			for s := range need {
+				if len(hands[s]) >= need[s] {
+					continue
+				}
+				if strict && trump != "" && b.mem.voids[s][effSuit(c, trump)] {
+					continue
+				}
+				hands[s] = append(hands[s], c)
+				placed = true
+				break
+			}
+			if !placed {
+				rest = append(rest, c)
+			}
+		}
+		for s := range need {
+			if len(hands[s]) < need[s] {
+				ok = false
+			}
+		}
+		if ok || !strict {
+			return hands, rest
+		}
+	}
+}
+
+// ----------------------------- Playouts -----------------------------
+
+// simTable is a bare copy of the play phase for playouts.
+type simTable struct {
+	hands  [][]Card
+	in     []bool
+	trump  string
+	lead   string
+	trick  []Card
+	by     []int
+	turn   int
+	tricks []int
+}
+
+func (t *simTable) active() int {
+	n := 0
+	for _, in := range t.in {
+		if in {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *simTable) done() bool {
+	if len(t.trick) > 0 {
+		return false
+	}
+	for s, h := range t.hands {
+		if t.in[s] && len(h) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *simTable) legal() []Card {
+	return legalPlays(t.hands[t.turn], t.lead, t.trump)
+}
+
+func (t *simTable) play(c Card) {
+	h := t.hands[t.turn]
+	for i := range h {
+		if sameCard(h[i], c) {
+			t.hands[t.turn] = append(h[:i:i], h[i+1:]...)
+			break
+		}
+	}
+	t.trick = append(t.trick, c)
+	t.by = append(t.by, t.turn)
+	if len(t.trick) == 1 {
+		t.lead = effSuit(c, t.trump)
+	}
+	if len(t.trick) == t.active() {
+		w := trickWinner(t.trick, t.by, t.trump)
+		t.tricks[w]++
+		t.turn = w
+		t.trick, t.by, t.lead = nil, nil, ""
+		return
+	}
+	n := len(t.hands)
+	for i := 1; i <= n; i++ {
+		if s := (t.turn + i) % n; t.in[s] {
+			t.turn = s
+			return
+		}
+	}
+}
+
+// rolloutMove is the playout policy: mostly "win as cheaply as possible,
+// else throw the lowest card", with some randomness.
+func (t *simTable) rolloutMove(rng *rand.Rand) Card {
+	legal := t.legal()
+	if len(legal) == 1 || rng.Intn(4) == 0 {
+		return legal[rng.Intn(len(legal))]
+	}
+	sorted := byStrength(legal, t.trump)
+	if len(t.trick) == 0 {
+		return sorted[len(sorted)-1]
+	}
+	best := t.trick[0]
+	for _, c := range t.trick[1:] {
+		if beats(c, best, t.lead, t.trump) {
+			best = c
+		}
+	}
+	for _, c := range sorted {
+		if beats(c, best, t.lead, t.trump) {
+			return c
+		}
+	}
+	return sorted[0]
+}
+
+func (t *simTable) playout(rng *rand.Rand) {
+	for !t.done() {
+		t.play(t.rolloutMove(rng))
+	}
+}
+
+// utility maps a seat's score change onto [0,1], higher is better.
+func utility(delta int) float64 {
+	u := (40 - float64(delta)) / 60
+	return math.Max(0, math.Min(1, u))
+}
+
+// ----------------------------- Decisions -----------------------------
+
+// seated returns the seats holding cards at the start of a hand.
+func seated(v *seatView) []bool {
+	in := make([]bool, len(v.Counts))
+	for s, n := range v.Counts {
+		in[s] = n > 0 || s == v.Seat
+	}
+	return in
+}
+
+// trickOdds plays sampled deals with us as declarer and trump as trumps and
+// returns how often we took 0..5 tricks.
+func (b *ismctsBot) trickOdds(v *seatView, trump string, lim searchLimits) [6]float64 {
+	var hist [6]float64
+	pool := b.unseen(v)
+	in := seated(v)
+	start := time.Now()
+	n := 0
+	for ; lim.more(start, n); n++ {
+		need := make([]int, len(in))
+		for s := range in {
+			if in[s] && s != v.Seat {
+				need[s] = 5
+			}
+		}
+		hands, _ := b.sampleHands(pool, need, "")
+		hands[v.Seat] = append([]Card(nil), v.You...)
+		t := &simTable{hands: hands, in: append([]bool(nil), in...), trump: trump, turn: v.Seat, tricks: make([]int, len(in))}
+		for s := range in {
+			// weak defenders go home, as most players would
+			if in[s] && s != v.Seat && trump != "clubs" && trumpStrength(hands[s], trump) < 0.8 {
+				t.in[s] = false
+			}
+		}
+		t.playout(b.rng)
+		hist[min(t.tricks[v.Seat], 5)]++
+	}
+	for k := range hist {
+		hist[k] /= float64(n)
+	}
+	return hist
+}
+
+func declarerValue(v *seatView, hist [6]float64, bid int, trump string) float64 {
+	ev := 0.0
+	for k, p := range hist {
+		ev += p * utility(handDelta(v.Seat, v.Seat, bid, k, false, trump, v.RoundDouble))
+	}
+	return ev
+}
+
+func (b *ismctsBot) bid(v *seatView) botAction {
+	othersIn := false
+	for s, n := range v.Counts {
+		if s != v.Seat && n > 0 && !v.passed(s) {
+			othersIn = true
+		}
+	}
+	if !othersIn && v.BestBy == -1 {
+		return botAction{T: "bid", M: map[string]any{"bid": 1}, Why: "everyone passed; take it at 1"}
+	}
+	next := v.BestBid + 1
+	if next > 5 {
+		return botAction{T: "pass", M: map[string]any{}, Why: "nothing left to outbid"}
+	}
+	lim := b.limits.split(len(suits))
+	bestSuit, bestVal, bestExp := "", -1.0, 0.0
+	for _, s := range suits {
+		if next == 1 && s != "hearts" {
+			continue // a bid of 1 plays hearts
+		}
+		hist := b.trickOdds(v, s, lim)
+		if val := declarerValue(v, hist, next, s); val > bestVal {
+			exp := 0.0
+			for k, p := range hist {
+				exp += float64(k) * p
+			}
+			bestSuit, bestVal, bestExp = s, val, exp
+		}
+	}
+	// defending is worth roughly a trick or two, or nothing from home
+	passVal := utility(-1)
+	if bestVal > passVal {
+		return botAction{T: "bid", M: map[string]any{"bid": next},
+			Why: fmt.Sprintf("simulations give about %.1f tricks with %s as trump", bestExp, bestSuit)}
+	}
+	return botAction{T: "pass", M: map[string]any{},
+		Why: fmt.Sprintf("simulations give about %.1f tricks at best; %d is too risky", bestExp, next)}
+}
+
+func (b *ismctsBot) pickTrump(v *seatView) botAction {
+	lim := b.limits.split(len(suits))
+	bestSuit, bestVal := suits[0], -1.0
+	for _, s := range suits {
+		hist := b.trickOdds(v, s, lim)
+		if val := declarerValue(v, hist, v.BestBid, s); val > bestVal {
+			bestSuit, bestVal = s, val
+		}
+	}
+	return botAction{T: "pick_trump", M: map[string]any{"trump": bestSuit},
+		Why: fmt.Sprintf("%s gives the best chance to make %d", bestSuit, v.BestBid)}
+}
+
+// exchange compares keeping, weakest-first discards and staying home on the
+// same sampled deals.
+func (b *ismctsBot) exchange(v *seatView) botAction {
+	type option struct {
+		discard []Card
+		home    bool
+		total   float64
+	}
+	options := []*option{{}}
+	if v.Seat != v.BestBy && v.Trump != "clubs" {
+		options = append(options, &option{home: true})
+	}
+	// only weakest-first discards: trying every subset on a small budget
+	// mostly rewards whichever option got lucky samples
+	weakest := byStrength(v.You, v.Trump)
+	for k := 1; k <= v.ExchangeMax && k <= len(weakest); k++ {
+		options = append(options, &option{discard: weakest[:k]})
+	}
+
+	pool := b.unseen(v)
+	in := seated(v)
+	start := time.Now()
+	n := 0
+	for ; b.limits.more(start, n*len(options)); n++ {
+		deck := append([]Card(nil), pool...)
+		b.rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+		need := make([]int, len(in))
+		for s := range in {
+			if in[s] && s != v.Seat && !v.stayed(s) {
+				need[s] = v.Counts[s]
+			}
+		}
+		// the first cards stand in for what we would draw; the talon's
+		// bottom card comes last, so the peek is ours only if we draw the
+		// talon dry
+		draw := deck[:min(v.ExchangeMax, len(deck))]
+		if p := b.peekInTalon(v); p != nil && v.Talon <= len(draw) {
+			draw[v.Talon-1] = *p
+		}
+		hands, _ := b.sampleHands(deck[len(draw):], need, v.Trump)
+		seed := b.rng.Int63()
+		for _, o := range options {
+			if o.home {
+				o.total += utility(0)
+				continue
+			}
+			t := &simTable{hands: make([][]Card, len(in)), in: make([]bool, len(in)), trump: v.Trump, turn: v.BestBy, tricks: make([]int, len(in))}
+			for s := range in {
+				t.hands[s] = append([]Card(nil), hands[s]...)
+				t.in[s] = in[s] && !v.stayed(s)
+			}
+			mine := keepAfterDiscard(v.You, o.discard)
+			t.hands[v.Seat] = append(mine, draw[:len(o.discard)]...)
+			t.playout(rand.New(rand.NewSource(seed)))
+			o.total += utility(handDelta(v.Seat, v.BestBy, v.BestBid, t.tricks[v.Seat], false, v.Trump, v.RoundDouble))
+		}
+	}
+	best := options[0]
+	for _, o := range options[1:] {
+		if o.total > best.total {
+			best = o
+		}
+	}
+	switch {
+	case best.home:
+		return botAction{T: "stay_home", M: map[string]any{}, Why: "simulations say you lose less at home"}
+	case len(best.discard) == 0:
+		return botAction{T: "exchange_done", M: map[string]any{}, Why: "no swap improves the hand"}
+	}
+	cards := make([]any, 0, len(best.discard))
+	names := make([]string, 0, len(best.discard))
+	for _, c := range best.discard {
+		cards = append(cards, map[string]any{"Suit": c.Suit, "Rank": c.Rank})
+		names = append(names, c.Suit+" "+c.Rank)
+	}
+	return botAction{T: "exchange", M: map[string]any{"cards": cards},
+		Why: "swap " + strings.Join(names, ", ")}
+}
+
+func keepAfterDiscard(hand, discard []Card) []Card {
+	var keep []Card
+	for _, c := range hand {
+		drop := false
+		for _, d := range discard {
+			if sameCard(c, d) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			keep = append(keep, c)
+		}
+	}
+	return keep
+}
+
+// ----------------------------- Card play search -----------------------------
+
+type isNode struct {
+	move   Card
+	player int
+	parent *isNode
+	kids   []*isNode
+	visits float64
+	avail  float64
+	reward float64
+}
+
+func (n *isNode) child(c Card) *isNode {
+	for _, k := range n.kids {
+		if sameCard(k.move, c) {
+			return k
+		}
+	}
+	return nil
+}
+
+// determinize builds one possible current table consistent with what the
+// seat has seen.
+func (b *ismctsBot) determinize(v *seatView) *simTable {
+	seats := len(v.Counts)
+	t := &simTable{
+		hands:  make([][]Card, seats),
+		in:     make([]bool, seats),
+		trump:  v.Trump,
+		lead:   v.Lead,
+		turn:   v.Turn,
+		tricks: make([]int, seats),
+	}
+	copy(t.tricks, v.Tricks)
+	for _, tc := range v.Trick {
+		t.trick = append(t.trick, normCard(tc.card()))
+		t.by = append(t.by, tc.By)
+	}
+	need := make([]int, seats)
+	for s := 0; s < seats; s++ {
+		inTrick := false
+		for _, by := range t.by {
+			inTrick = inTrick || by == s
+		}
+		t.in[s] = !v.stayed(s) && (v.Counts[s] > 0 || inTrick || s == v.Seat)
+		if t.in[s] && s != v.Seat {
+			need[s] = v.Counts[s]
+		}
+	}
+	hands, _ := b.sampleHands(b.unseen(v), need, v.Trump)
+	for s := range hands {
+		t.hands[s] = hands[s]
+	}
+	t.hands[v.Seat] = append([]Card(nil), v.You...)
+	return t
+}
+
+func (b *ismctsBot) play(v *seatView) botAction {
+	legal := legalPlays(v.You, v.Lead, v.Trump)
+	if len(legal) == 1 {
+		return b.playAction(v, legal[0], "only legal card")
+	}
+	root := &isNode{player: -1}
+	start := time.Now()
+	for i := 0; b.limits.more(start, i); i++ {
+		t := b.determinize(v)
+		node := root
+		// select
+		for !t.done() {
+			moves := t.legal()
+			var untried []Card
+			for _, m := range moves {
+				if node.child(m) == nil {
+					untried = append(untried, m)
+				}
+			}
+			if len(untried) > 0 {
+				m := untried[b.rng.Intn(len(untried))]
+				k := &isNode{move: m, player: t.turn, parent: node}
+				node.kids = append(node.kids, k)
+				t.play(m)
+				node = k
+				break
+			}
+			var best *isNode
+			bestScore := -1.0
+			for _, m := range moves {
+				k := node.child(m)
+				k.avail++
+				score := k.reward/k.visits + ismctsExplore*math.Sqrt(math.Log(k.avail)/k.visits)
+				if score > bestScore {
+					best, bestScore = k, score
+				}
+			}
+			t.play(best.move)
+			node = best
+		}
+		t.playout(b.rng)
+		for n := node; n != root; n = n.parent {
+			n.visits++
+			n.reward += utility(handDelta(n.player, v.BestBy, v.BestBid, t.tricks[n.player], false, v.Trump, v.RoundDouble))
+		}
+	}
+	var best *isNode
+	for _, k := range root.kids {
+		if best == nil || k.visits > best.visits {
+			best = k
+		}
+	}
+	if best == nil {
+		return heuristicPlay(v)
+	}
+	why := fmt.Sprintf("best in %.0f of %d simulations", best.visits, int(root.visitsOfKids()))
+	if effSuit(legal[0], v.Trump) == v.Lead && v.Lead != "" {
+		why = joinWhy("must follow "+v.Lead, why)
+	}
+	return b.playAction(v, best.move, why)
+}
+
+func (n *isNode) visitsOfKids() float64 {
+	sum := 0.0
+	for _, k := range n.kids {
+		sum += k.visits
+	}
+	return sum
+}
+
+func (b *ismctsBot) playAction(v *seatView, c Card, why string) botAction {
+	return botAction{T: "move", M: map[string]any{
+		"type": "play_card",
+		"card": map[string]any{"Suit": c.Suit, "Rank": c.Rank},
+	}, Why: why}
+}
@@ -0,0 +1,125 @@
+package ws
+
+import "time"
+
+// Time banks: with Rules.TimeBank set, every seat starts each hand with
+// that many seconds. The bank of the seat the game is waiting on runs down
+// and gains Rules.Increment once the decision is made. When a bank runs dry
+// the default move is played for the seat and it takes Rules.TimePenalty
+// (once per hand), or with OutOfTime "forfeit" it loses the hand outright.
+
+const (
+	outOfTimePenalty = "penalty"
+	outOfTimeForfeit = "forfeit"
+)
+
+// resetClocks fills every bank for a new hand. Runs on the room's goroutine.
+func resetClocks(r *Room) {
+	stopClock(r)
+	r.Clocks = make(map[int]time.Duration, r.Seats)
+	r.flagged = make(map[int]bool)
+	if r.Rules.TimeBank <= 0 {
+		return
+	}
+	for s := 0; s < r.Seats; s++ {
+		r.Clocks[s] = time.Duration(r.Rules.TimeBank) * time.Second
+	}
+}
+
+// runClock charges the seat whose decision just ended and starts the clock
+// of the seat the game now waits on. Runs on the room's goroutine.
+func (h *Hub) runClock(r *Room) {
+	if h.stopped.Load() {
+		// the banks stay as the shutdown snapshot saved them
+		return
+	}
+	seat := actingSeat(r)
+	key := ""
+	if seat >= 0 {
+		key = turnKey(r, seat)
+	}
+	if key == r.clockKey && r.clockSeat == seat {
+		return
+	}
+	now := time.Now()
+	if prev := r.clockSeat; prev >= 0 {
+		left := max(r.Clocks[prev]-now.Sub(r.clockSince), 0)
+		r.Clocks[prev] = left + time.Duration(r.Rules.Increment)*time.Second
+	}
+	stopClock(r)
+	if seat < 0 || r.Rules.TimeBank <= 0 || r.closed {
+		return
+	}
+	r.clockSeat = seat
+	r.clockSince = now
+	r.clockKey = key
+	r.flagTimer = time.AfterFunc(r.Clocks[seat], func() { h.flagFall(r, key) })
+}
+
+// stopClock halts the running bank without charging it. Runs on the room's goroutine.
+func stopClock(r *Room) {
+	if r.flagTimer != nil {
+		r.flagTimer.Stop()
+		r.flagTimer = nil
+	}
+	r.clockSeat = -1
+	r.clockKey = ""
+}
+
+// clockLeft is a seat's bank as of now. Runs on the room's goroutine.
+func clockLeft(r *Room, seat int) time.Duration {
+	left := r.Clocks[seat]
+	if seat == r.clockSeat {
+		left -= time.Since(r.clockSince)
+	}
+	return max(left, 0)
+}
+
+func (h *Hub) flagFall(r *Room, key string) {
+	r.do(func() {
+		if h.stopped.Load() || r.clockKey != key || r.clockSeat < 0 {
+			return
+		}
+		seat := r.clockSeat
+		h.metrics.timeouts.Inc("time_bank")
+		r.Clocks[seat] = 0
+		r.clockSince = time.Now()
+
+		if r.Rules.OutOfTime == outOfTimeForfeit {
+			h.forfeitHand(r, seat, "out of time")
+			h.broadcastRoom(r, "flag", map[string]any{"room": r.ID, "seat": seat, "result": outOfTimeForfeit})
+			h.broadcastState(r)
+			return
+		}
+		logAction(r, seat, "out_of_time", "")
+		penalty := 0
+		if !r.flagged[seat] {
+			penalty = r.Rules.TimePenalty
+			r.Scores[seat] += penalty
+			r.flagged[seat] = true
+		}
+		typ, m := defaultAction(r, seat)
+		h.broadcastRoom(r, "flag", map[string]any{"room": r.ID, "seat": seat, "result": outOfTimePenalty, "penalty": penalty})
+		h.actFor(r, seat, typ, m)
+	})
+}
+
+// actFor plays an action for seat through the session holding it, as if
+// its client had sent it. Runs on the room's goroutine, so the action
+// meets the state it was chosen for.
+func (h *Hub) actFor(r *Room, seat int, typ string, m map[string]any) {
+	if h.stopped.Load() {
+		return
+	}
+	if r.Sessions[seat] == nil && r.PlayerIDs[seat] != "" {
+		// a player not back since a restart: a bot takes over
+		h.standIn(r, seat)
+	}
+	s := r.Sessions[seat]
+	if s == nil {
+		return
+	}
+	// errors from the handler go nowhere
+	c := &Client{hub: h, send: make(chan []byte, 8), sess: s}
+	h.handleRoomMessage(c, r, typ, m)
+}
@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+// clockRoom deals a hand at a table whose banks are running, with the
+// given out-of-time rule.
+func clockRoom(t *testing.T, h *Hub, outOfTime string) *Room {
+	room := newRoom("clock-"+outOfTime, 3, "p-0", 7)
+	room.Rules.TimeBank = 60
+	room.Rules.Increment = 5
+	room.Rules.OutOfTime = outOfTime
+	room.Rules.TimePenalty = 7
+	seatHeadless(h, room, "p")
+	h.addRoom(room)
+	t.Cleanup(func() { room.do(func() { h.removeRoom(room) }) })
+	room.do(func() { h.startHand(room) })
+	return room
+}
+
+// TestFlagFallPenalty runs a bank out twice in one hand: the seat takes the
+// penalty once and its default move is played each time.
+func TestFlagFallPenalty(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := clockRoom(t, h, outOfTimePenalty)
+
+	var key, phase string
+	var seat int
+	var scores map[int]int
+	state := func() {
+		room.do(func() {
+			key, phase, seat, scores = room.clockKey, room.Phase, room.clockSeat, maps.Clone(room.Scores)
+		})
+	}
+	state()
+	if phase != "start" || key == "" || seat < 0 {
+		t.Fatalf("no bank running: phase %q, key %q, seat %d", phase, key, seat)
+	}
+	flagged := seat
+	h.flagFall(room, key)
+	state()
+	if phase != "cut" || seat != flagged {
+		t.Fatalf("phase %q, seat %d after the flag fell; want cut, %d", phase, seat, flagged)
+	}
+	if scores[flagged] != 7 {
+		t.Fatalf("seat %d score %d, want the penalty of 7", flagged, scores[flagged])
+	}
+	var left time.Duration
+	room.do(func() { left = room.Clocks[flagged] })
+	if left != 5*time.Second {
+		t.Fatalf("bank %v after the flag fell, want just the increment", left)
+	}
+
+	// the same seat runs out again: the move is made, the penalty not
+	// taken twice
+	h.flagFall(room, key)
+	state()
+	if phase != "bidding" || scores[flagged] != 7 {
+		t.Fatalf("second flag: phase %q, score %d", phase, scores[flagged])
+	}
+}
+
+// TestFlagFallForfeit runs a bank out under the forfeit rule: the hand
+// ends there, booked as lost for the seat alone.
+func TestFlagFallForfeit(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := clockRoom(t, h, outOfTimeForfeit)
+
+	var key string
+	var seat, loss int
+	room.do(func() {
+		key, seat = room.clockKey, room.clockSeat
+		loss = handDelta(seat, seat, max(room.BestBid, 1), 0, false, room.Trump, room.RoundDouble)
+	})
+	h.flagFall(room, key)
+	var over bool
+	var phase string
+	var scores map[int]int
+	room.do(func() { over, phase, scores = room.HandOver, room.Phase, maps.Clone(room.Scores) })
+	if !over || phase != "" {
+		t.Fatalf("hand still on: over %v, phase %q", over, phase)
+	}
+	for s := 0; s < 3; s++ {
+		want := 0
+		if s == seat {
+			want = loss
+		}
+		if scores[s] != want {
+			t.Errorf("seat %d score %d, want %d", s, scores[s], want)
+		}
+	}
+	if res, _ := h.store.Results(room.ID); len(res) != 1 {
+		t.Fatalf("%d results stored, want 1", len(res))
+	}
+}
@@ -0,0 +1,212 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/backplane"
+)
+
+// Clustering: hubs sharing a backplane serve one lobby. A room lives on the
+// instance that created it, which claims its id and invite code on the
+// backplane and keeps the claims fresh. The lobby list merges every
+// instance's public tables; a client asking for a room held elsewhere gets
+// a "redirect" with the owner's URL and reconnects there. Alone, a hub
+// runs on an in-process backplane and owns everything.
+
+const (
+	defaultClusterInterval = 10 * time.Second
+	clusterTimeout         = 2 * time.Second // per backplane round trip
+)
+
+// SetBackplane joins the hub to other instances as self. Call before
+// serving.
+func (h *Hub) SetBackplane(bp backplane.Backplane, self backplane.Instance) {
+	h.bp, h.self = bp, self
+}
+
+// StartCluster registers the hub and, every interval until ctx is done,
+// refreshes its claims and lobby listing and picks up the other
+// instances' tables. Entries lapse after three missed refreshes.
+func (h *Hub) StartCluster(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = defaultClusterInterval
+	}
+	h.claimTTL = 3 * every
+	h.syncCluster()
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if !h.stopped.Load() {
+					h.syncCluster()
+				}
+			}
+		}
+	}()
+}
+
+func (h *Hub) syncCluster() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*clusterTimeout)
+	defer cancel()
+	if err := h.bp.Register(ctx, h.self, h.claimTTL); err != nil {
+		log.Printf("backplane: register: %v", err)
+		return
+	}
+	now := time.Now()
+	var keys []string
+	for _, r := range h.allRooms() {
+		sum := r.summary.Load()
+		keys = append(keys, r.ID)
+		if sum.inviteCode != "" && now.Before(sum.inviteExpires) {
+			keys = append(keys, codeKey(sum.inviteCode))
+		}
+	}
+	h.claim(ctx, keys...)
+
+	list, _ := json.Marshal(h.localTables())
+	if err := h.bp.PutTables(ctx, h.self.ID, list, h.claimTTL); err != nil {
+		log.Printf("backplane: tables: %v", err)
+	}
+	all, err := h.bp.Tables(ctx)
+	if err != nil {
+		log.Printf("backplane: tables: %v", err)
+		return
+	}
+	var remote []roomInfo
+	for id, raw := range all {
+		var l []roomInfo
+		if id == h.self.ID || json.Unmarshal(raw, &l) != nil {
+			continue
+		}
+		remote = append(remote, l...)
+	}
+	slices.SortFunc(remote, func(a, b roomInfo) int { return strings.Compare(a.ID, b.ID) })
+	h.remoteMu.Lock()
+	changed := !slices.Equal(h.remote, remote)
+	h.remote = remote
+	h.remoteMu.Unlock()
+	if changed {
+		h.broadcastRoomsList()
+	}
+}
+
+// claim takes ownership of room ids and invite code keys for this hub.
+func (h *Hub) claim(ctx context.Context, keys ...string) {
+	for _, k := range keys {
+		owner, err := h.bp.Claim(ctx, k, h.self.ID, h.claimTTL)
+		if err != nil {
+			log.Printf("backplane: claim %s: %v", k, err)
+		} else if owner != h.self.ID {
+			log.Printf("backplane: %s is held by %s as well", k, owner)
+		}
+	}
+}
+
+// claimRoom claims a room that was just created or got a new invite. It
+// returns at once; see queueClaim.
+func (h *Hub) claimRoom(id, code string) {
+	keys := []string{id}
+	if code != "" {
+		keys = append(keys, codeKey(code))
+	}
+	h.queueClaim(func(ctx context.Context) {
+		h.claim(ctx, keys...)
+	})
+}
+
+// releaseRoom gives up the claims of a deleted room. It returns at once;
+// see queueClaim.
+func (h *Hub) releaseRoom(id, code string) {
+	keys := []string{id}
+	if code != "" {
+		keys = append(keys, codeKey(code))
+	}
+	h.queueClaim(func(ctx context.Context) {
+		for _, k := range keys {
+			if err := h.bp.Release(ctx, k, h.self.ID); err != nil {
+				log.Printf("backplane: release %s: %v", k, err)
+			}
+		}
+	})
+}
+
+// queueClaim runs op on the backplane off the caller's goroutine, so rooms
+// never wait on it. Ops run one at a time in the order they were queued,
+// so a room's release can't overtake its claim.
+func (h *Hub) queueClaim(op func(ctx context.Context)) {
+	h.claimsMu.Lock()
+	h.claims = append(h.claims, op)
+	start := !h.claimBusy
+	h.claimBusy = true
+	h.claimsMu.Unlock()
+	if start {
+		go h.runClaims()
+	}
+}
+
+func (h *Hub) runClaims() {
+	for {
+		h.claimsMu.Lock()
+		if len(h.claims) == 0 {
+			h.claimBusy = false
+			h.claimsMu.Unlock()
+			return
+		}
+		op := h.claims[0]
+		h.claims = h.claims[1:]
+		h.claimsMu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		op(ctx)
+		cancel()
+	}
+}
+
+func codeKey(code string) string {
+	return "code:" + strings.ToUpper(code)
+}
+
+// redirect points c at the instance holding roomID, or the room behind
+// code, when that isn't this one. It reports whether it did.
+func (h *Hub) redirect(c *Client, roomID, code string) bool {
+	key := roomID
+	if code != "" {
+		key = codeKey(code)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	owner, err := h.bp.Owner(ctx, key)
+	if err != nil {
+		log.Printf("backplane: owner of %s: %v", key, err)
+		return false
+	}
+	if owner == "" || owner == h.self.ID {
+		return false
+	}
+	instances, err := h.bp.Instances(ctx)
+	if err != nil {
+		log.Printf("backplane: instances: %v", err)
+		return false
+	}
+	for _, in := range instances {
+		if in.ID == owner && in.URL != "" {
+			m := map[string]any{"url": in.URL, "instance": owner}
+			if code != "" {
+				m["code"] = code
+			} else {
+				m["room"] = roomID
+			}
+			h.send(c, "redirect", m)
+			return true
+		}
+	}
+	return false
+}
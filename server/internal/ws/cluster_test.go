@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/backplane"
+)
+
+// headless is a client with no connection; what the hub sends it queues up
+// in c.send.
+func headless(h *Hub, id string) *Client {
+	c := &Client{hub: h, send: make(chan []byte, 64)}
+	h.sessionFor(id).attach(c)
+	return c
+}
+
+func sendRaw(h *Hub, c *Client, typ string, m map[string]any) {
+	data, _ := json.Marshal(map[string]any{"t": typ, "m": m})
+	h.handleRaw(c, data)
+}
+
+// expect skips c's messages until one of type typ arrives.
+func expect(t *testing.T, c *Client, typ string) map[string]any {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case b := <-c.send:
+			var env struct {
+				T string         `json:"t"`
+				M map[string]any `json:"m"`
+			}
+			if err := json.Unmarshal(b, &env); err != nil {
+				t.Fatal(err)
+			}
+			if env.T == typ {
+				return env.M
+			}
+		case <-timeout:
+			t.Fatalf("no %q message", typ)
+		}
+	}
+}
+
+// eventually fails t unless ok holds within a second.
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	for end := time.Now().Add(time.Second); !ok(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+	}
+}
+
+// TestCluster runs two hubs on one Loopback: a table created on one is
+// claimed by it, listed in the other's lobby, and a client asking the other
+// for it is sent on to its owner. Closing the table gives the claims up.
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bp := backplane.NewLoopback()
+	a, b := NewHub(nil), NewHub(nil)
+	a.SetBackplane(bp, backplane.Instance{ID: "a", URL: "ws://a/ws"})
+	b.SetBackplane(bp, backplane.Instance{ID: "b", URL: "ws://b/ws"})
+	a.StartCluster(ctx, time.Hour)
+	b.StartCluster(ctx, time.Hour)
+
+	owner := func(key string) string {
+		o, err := bp.Owner(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+
+	alice := headless(a, "alice")
+	sendRaw(a, alice, "create_table", map[string]any{"seats": 2, "invite": true})
+	created := expect(t, alice, "created")
+	id, code := created["room"].(string), created["code"].(string)
+	eventually(t, "a owns the room", func() bool { return owner(id) == "a" })
+	eventually(t, "a owns the invite code", func() bool { return owner(codeKey(code)) == "a" })
+	if o, _ := bp.Claim(ctx, id, "b", time.Minute); o != "a" {
+		t.Fatalf("b claimed a's room: owner %q", o)
+	}
+
+	// the lobby on b merges a's tables once both have synced
+	a.syncCluster()
+	b.syncCluster()
+	listed := false
+	for _, info := range b.roomsList()["list"].([]roomInfo) {
+		listed = listed || info.ID == id
+	}
+	if !listed {
+		t.Fatalf("room %s missing from b's lobby: %v", id, b.roomsList())
+	}
+
+	bob := headless(b, "bob")
+	sendRaw(b, bob, "join_table", map[string]any{"room": id})
+	if m := expect(t, bob, "redirect"); m["url"] != "ws://a/ws" || m["room"] != id {
+		t.Fatalf("redirect by id: %v", m)
+	}
+	sendRaw(b, bob, "join_table", map[string]any{"code": code})
+	if m := expect(t, bob, "redirect"); m["url"] != "ws://a/ws" || m["code"] != code {
+		t.Fatalf("redirect by code: %v", m)
+	}
+	// a room nobody holds is just not found
+	sendRaw(b, bob, "join_table", map[string]any{"room": "nowhere"})
+	expect(t, bob, "error")
+
+	sendRaw(a, alice, "join_table", map[string]any{"room": id})
+	expect(t, alice, "state")
+	sendRaw(a, alice, "leave_table", map[string]any{"room": id})
+	eventually(t, "the room's claims are released", func() bool {
+		return owner(id) == "" && owner(codeKey(code)) == ""
+	})
+}
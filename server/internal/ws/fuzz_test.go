@@ -0,0 +1,167 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// FuzzHand deals a hand at a 2-5 seat table and plays it through handleRaw,
+// the entry point client messages take. script picks among the legal
+// actions of the acting seat and now and then sends a junk message
+// instead, from any seat or from outside the table; once it runs out, the
+// first legal action is taken. The hand must finish without a panic, an
+// invariant violation, a rejected legal action, a move by a seat that
+// wasn't to act or a phase nobody can act in.
+//
+// Failing inputs are written to testdata/fuzz/FuzzHand and replay with
+// plain `go test`.
+func FuzzHand(f *testing.F) {
+	f.Add(uint8(3), int64(1), []byte{})
+	f.Add(uint8(2), int64(2), []byte{1, 0, 0, 7, 3, 2, 1, 9, 4})
+	f.Add(uint8(4), int64(3), []byte{0, 0, 5, 5, 5, 15, 2, 2, 2, 2, 3, 3, 3})
+	f.Add(uint8(5), int64(4), []byte{1, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+	// seat 0 sends start_choice naming seat 1, the first bidder
+	f.Add(uint8(1), int64(5), []byte{7, 1, 2, 0})
+	f.Fuzz(func(t *testing.T, seats uint8, seed int64, script []byte) {
+		n := 2 + int(seats)%4
+		h := NewHub(nil)
+		h.botDelay = 0
+		h.SetDebug(true)
+		var violation string // set on the room's goroutine
+		h.onViolation = func(msg string) { violation = msg }
+		room := newRoom("fuzz", n, "", seed)
+		players := seatHeadless(h, room, "p")
+		h.addRoom(room)
+		defer room.do(func() { h.removeRoom(room) })
+		// someone at no seat, for junk from outside the table
+		outsider := &Client{hub: h, send: make(chan []byte, 1), sess: newSession("fuzz")}
+
+		pos := 0
+		next := func() int {
+			if pos >= len(script) {
+				return 0
+			}
+			pos++
+			return int(script[pos-1])
+		}
+		send := func(seat int, typ string, m map[string]any) {
+			c := outsider
+			if seat >= 0 && seat < n {
+				c = players[seat]
+			}
+			m["room"] = room.ID
+			if _, ok := m["seat"]; !ok {
+				m["seat"] = seat
+			}
+			data, _ := json.Marshal(map[string]any{"t": typ, "m": m})
+			h.handleRaw(c, data)
+			if violation != "" {
+				t.Fatalf("invariant: %s", violation)
+			}
+		}
+
+		room.do(func() { h.startHand(room) })
+		if violation != "" {
+			t.Fatalf("invariant: %s", violation)
+		}
+		for step := 0; room.Phase != ""; step++ {
+			if step > 1000 {
+				t.Fatalf("hand still in %q after %d steps", room.Phase, step)
+			}
+			seat := actingSeat(room)
+			if seat < 0 {
+				t.Fatalf("nobody can act in %q", room.Phase)
+			}
+			b := next()
+			if b%8 == 7 {
+				from, typ, m := junkAction(room, next)
+				key := turnKey(room, seat)
+				send(from, typ, m)
+				if from != seat && typ != "hint" && turnKey(room, seat) != key {
+					t.Fatalf("seat %d moved the game with %s %v while %d was to act", from, typ, m, seat)
+				}
+				continue
+			}
+			opts := legalActions(room, seat, next)
+			if len(opts) == 0 {
+				t.Fatalf("seat %d has no legal action in %q", seat, room.Phase)
+			}
+			a := opts[b%len(opts)]
+			key := turnKey(room, seat)
+			send(seat, a.T, a.M)
+			if room.Phase != "" && actingSeat(room) == seat && turnKey(room, seat) == key {
+				t.Fatalf("seat %d: legal %s %v rejected in %q", seat, a.T, a.M, room.Phase)
+			}
+		}
+		if !room.HandOver {
+			t.Fatalf("phase ended without HandOver")
+		}
+	})
+}
+
+// legalActions lists what seat may do now; pick chooses exchange sets.
+func legalActions(r *Room, seat int, pick func() int) []botAction {
+	act := func(t string, m map[string]any) botAction { return botAction{T: t, M: m} }
+	var out []botAction
+	switch r.Phase {
+	case "start":
+		out = append(out, act("start_choice", map[string]any{"choice": "cut"}),
+			act("start_choice", map[string]any{"choice": "knock"}))
+	case "cut":
+		out = append(out, act("cut_proceed", map[string]any{}))
+	case "bidding":
+		if r.BestBy != -1 || countActiveBidders(r) > 1 {
+			out = append(out, act("pass", map[string]any{}))
+		}
+		for b := r.BestBid + 1; b <= 5; b++ {
+			out = append(out, act("bid", map[string]any{"bid": b}))
+		}
+	case "pick_trump":
+		for _, s := range suits {
+			out = append(out, act("pick_trump", map[string]any{"trump": s}))
+		}
+	case "exchange":
+		out = append(out, act("exchange_done", map[string]any{}))
+		if seat != r.BestBy && r.Trump != "clubs" {
+			out = append(out, act("stay_home", map[string]any{}))
+		}
+		hand := r.Hands[seat]
+		var cards []any
+		for k := 1 + pick()%r.exchangeMax; k > 0 && len(hand) > 0; k-- {
+			i := pick() % len(hand)
+			cards = append(cards, map[string]any{"Suit": hand[i].Suit, "Rank": hand[i].Rank})
+			hand = append(append([]Card(nil), hand[:i]...), hand[i+1:]...)
+		}
+		out = append(out, act("exchange", map[string]any{"cards": cards}))
+	case "play":
+		for _, c := range legalPlays(r.Hands[seat], r.Lead, r.Trump) {
+			out = append(out, act("move", map[string]any{
+				"type": "play_card",
+				"card": map[string]any{"Suit": c.Suit, "Rank": c.Rank},
+			}))
+		}
+	}
+	return out
+}
+
+// junkAction builds a message a buggy or hostile client might send, and
+// the seat it comes from (-1 or r.Seats for someone not seated): someone
+// else's seat, wrong phase, impossible values or cards it doesn't hold.
+func junkAction(r *Room, pick func() int) (int, string, map[string]any) {
+	from := pick()%(r.Seats+2) - 1
+	seat := pick()%(r.Seats+2) - 1
+	types := []string{"start_choice", "cut_proceed", "pass", "bid", "pick_trump",
+		"stay_home", "exchange", "exchange_done", "move", "hint", "bogus"}
+	typ := types[pick()%len(types)]
+	card := map[string]any{"Suit": suits[pick()%len(suits)], "Rank": "ace"}
+	m := map[string]any{
+		"seat":   seat,
+		"choice": "knock",
+		"bid":    pick()%8 - 1,
+		"trump":  []string{"hearts", "stars", ""}[pick()%3],
+		"type":   "play_card",
+		"card":   card,
+		"cards":  []any{card, card, card, card},
+	}
+	return from, typ, m
+}
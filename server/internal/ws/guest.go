@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/auth"
+)
+
+// Guest tokens let players without an account reconnect as themselves:
+// POST /auth/guest issues a signed token with a generated guest ID, and
+// set_name re-issues it with the new name. ServeWS accepts them like any
+// other token.
+
+const (
+	defaultGuestTTL = 30 * 24 * time.Hour
+	maxNameLen      = 24
+)
+
+func (h *Hub) guestTTL() time.Duration {
+	if h.auth.GuestTTL > 0 {
+		return h.auth.GuestTTL
+	}
+	return defaultGuestTTL
+}
+
+func guestsEnabled(cfg AuthConfig) bool {
+	return cfg.Guests && cfg.Mode != "" && cfg.Mode != AuthOff && len(cfg.Secret) > 0
+}
+
+// newGuestID makes a guest ID. Anonymous sessions get one too, so the
+// token issued to them later names the same ID as one from /auth/guest.
+func newGuestID() string {
+	return "guest-" + randID()
+}
+
+// guestToken signs a guest token for id and name.
+func (h *Hub) guestToken(id, name string) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(h.guestTTL())
+	tok, err := auth.Sign(h.auth.Secret, auth.Claims{
+		Sub: id, Name: name, Guest: true, Iat: now.Unix(), Exp: exp.Unix(),
+	})
+	return tok, exp, err
+}
+
+// issueGuestToken sends c's session a guest token carrying its name, so it can
+// reconnect under the same ID. Anonymous connections become guests;
+// account holders keep their own token.
+func (h *Hub) issueGuestToken(c *Client, name string) {
+	s := c.sess
+	if _, authed, guest := s.identity(); !guestsEnabled(h.auth) || s.bot != nil || (authed && !guest) {
+		return
+	}
+	tok, exp, err := h.guestToken(s.id, name)
+	if err != nil {
+		return
+	}
+	s.setIdentity(name, true, true)
+	h.sendSession(s, "guest_token", map[string]any{
+		"token":   tok,
+		"id":      s.id,
+		"name":    name,
+		"expires": exp.Unix(),
+	})
+}
+
+func cleanName(s string) string {
+	s = strings.TrimSpace(s)
+	for utf8.RuneCountInString(s) > maxNameLen {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+// ServeGuestToken handles POST /auth/guest with {"name": ..., "token": ...}.
+// A still-valid guest token keeps its guest ID; otherwise a new one is made.
+func (h *Hub) ServeGuestToken(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.allowOrigins[origin] {
+			http.Error(w, "forbidden origin", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Vary", "Origin")
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !guestsEnabled(h.auth) {
+		http.Error(w, "guest tokens are disabled", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	id := newGuestID()
+	if req.Token != "" {
+		if c, err := auth.Verify(h.auth.Secret, req.Token, time.Now()); err == nil && c.Guest {
+			id = c.Sub
+		}
+	}
+	name := cleanName(req.Name)
+	tok, exp, err := h.guestToken(id, name)
+	if err != nil {
+		http.Error(w, "could not sign token", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"token":   tok,
+		"id":      id,
+		"name":    name,
+		"expires": exp.Unix(),
+	})
+}
@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryEntry is one line of a hand's log. Details never reveal hidden
+// cards: exchanges show only how many cards went, hints only that one was
+// asked for.
+type HistoryEntry struct {
+	Seat   int       `json:"seat"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// logAction appends to the current hand's history. Runs on the room's
+// goroutine.
+func logAction(r *Room, seat int, action, detail string) {
+	r.History = append(r.History, HistoryEntry{Seat: seat, Action: action, Detail: detail, At: time.Now()})
+}
+
+func cardName(c Card) string {
+	return strings.ToLower(c.Rank) + " of " + strings.ToLower(c.Suit)
+}
+
+// hint runs the heuristic bot on seat's own view and returns its advice.
+// Runs on the room's goroutine.
+func (h *Hub) hint(r *Room, seat int) (botAction, bool) {
+	v := parseState(h.stateMsg(r, seat))
+	if v == nil {
+		return botAction{}, false
+	}
+	return (&heuristicBot{}).Act(v)
+}
+
+// hintMsg is the "hint" reply: the suggested message type, its fields and
+// the reason in words.
+func hintMsg(roomID string, a botAction) map[string]any {
+	out := map[string]any{"room": roomID, "action": a.T, "why": a.Why}
+	for k, v := range a.M {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestHint asks for a hint at every decision of a hand and plays it: each
+// one is accepted, so legal, and logged against the seat that asked.
+func TestHint(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("hint", 3, "p-0", 7)
+	players := make([]*Client, room.Seats)
+	for seat := range players {
+		players[seat] = headless(h, fmt.Sprintf("p-%d", seat))
+		players[seat].sess.sit(room.ID, seat)
+		room.PlayerIDs[seat], room.Sessions[seat] = players[seat].sess.id, players[seat].sess
+	}
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+	room.do(func() { h.startHand(room) })
+
+	sendRaw(h, players[0], "hint", map[string]any{"room": room.ID})
+	if msg := expect(t, players[0], "error"); msg["msg"] != "hints are off at this table" {
+		t.Fatalf("hint with hints off: %v", msg)
+	}
+	room.do(func() { room.Rules.Hints = true })
+
+	for step := 0; ; step++ {
+		var seat int
+		var key string
+		var over bool
+		var logged int
+		room.do(func() {
+			seat, over, logged = actingSeat(room), room.HandOver, len(room.History)
+			if seat >= 0 {
+				key = turnKey(room, seat)
+			}
+		})
+		if over {
+			break
+		}
+		if step > 100 || seat < 0 {
+			t.Fatalf("stuck at step %d", step)
+		}
+		if step == 0 {
+			idle := players[(seat+1)%room.Seats]
+			sendRaw(h, idle, "hint", map[string]any{"room": room.ID})
+			if msg := expect(t, idle, "error"); msg["msg"] != "nothing to decide right now" {
+				t.Fatalf("hint out of turn: %v", msg)
+			}
+		}
+
+		c := players[seat]
+		sendRaw(h, c, "hint", map[string]any{"room": room.ID})
+		hint := expect(t, c, "hint")
+		typ, _ := hint["action"].(string)
+		if typ == "" || hint["why"] == "" {
+			t.Fatalf("hint %v", hint)
+		}
+		var entry HistoryEntry
+		room.do(func() {
+			if len(room.History) > logged {
+				entry = room.History[logged]
+			}
+		})
+		if entry.Action != "hint" || entry.Seat != seat {
+			t.Fatalf("hint to seat %d not logged: %+v", seat, entry)
+		}
+
+		delete(hint, "action")
+		delete(hint, "why")
+		sendRaw(h, c, typ, hint)
+		var moved bool
+		room.do(func() { moved = room.HandOver || actingSeat(room) != seat || turnKey(room, seat) != key })
+		if !moved {
+			t.Fatalf("hinted %s %v not accepted in %s", typ, hint, key)
+		}
+	}
+}
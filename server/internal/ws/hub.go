@@ -3,17 +3,25 @@ package ws
 import (
 	"context"
 	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"nhooyr.io/websocket"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/backplane"
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/store"
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/util"
 )
 
 // ----------------------------- Types & Models -----------------------------
@@ -23,14 +31,73 @@ type Card struct {
 	Rank string `json:"Rank"`
 }
 
+// RoomRules are the per-table options chosen at create_table.
+type RoomRules struct {
+	RandomSeats  bool `json:"randomSeats"`  // shuffle seating when the match starts
+	RandomDealer bool `json:"randomDealer"` // pick the first dealer at random
+
+	// What happens to a hand when a player leaves or disconnects mid-hand
+	// (see abandon.go): "bot" lets a TakeoverBot play on, "void" redeals
+	// without the leaver, "lose" ends the hand scored as lost for them.
+	OnLeave     string `json:"onLeave"`
+	TakeoverBot string `json:"takeoverBot"`
+
+	// Seconds the acting seat gets per phase ("bidding", "play", ...)
+	// before the server acts for it; missing or 0 means no limit.
+	TurnSeconds map[string]int `json:"turnSeconds,omitempty"`
+
+	// Chess-clock banks (see clock.go): seconds per seat per hand, seconds
+	// added after each decision, and what happens when a bank runs dry
+	// ("penalty" adds TimePenalty points and plays the default move,
+	// "forfeit" loses the hand).
+	TimeBank    int    `json:"timeBank,omitempty"`
+	Increment   int    `json:"increment,omitempty"`
+	OutOfTime   string `json:"outOfTime,omitempty"`
+	TimePenalty int    `json:"timePenalty,omitempty"`
+
+	// Hints lets players ask the server for a suggested move.
+	Hints bool `json:"hints"`
+}
+
+func defaultRules() RoomRules {
+	return RoomRules{OnLeave: leaveBot, TakeoverBot: "heuristic"}
+}
+
 type Room struct {
 	ID    string
 	Game  string
 	Seats int
-
-	// Connections
-	Conns     map[int]*Client // seat -> client
-	PlayerIDs []string        // seat -> client.id ("" if empty)
+	Rules RoomRules
+	Host  string // session id of the host; passes on when the host leaves
+
+	// Host controls: a locked room refuses joins; kicked players stay out
+	Locked bool
+	banned map[string]bool
+
+	CreatedAt  time.Time
+	lastActive time.Time // last action; drives janitor expiry
+	snapshotAt time.Time // last snapshot (see snapshot.go)
+
+	// Access: private rooms are left out of the lobby list and need an
+	// invite code or the password to join.
+	Private       bool
+	InviteCode    string // "" if none
+	InviteExpires time.Time
+	passHash      []byte // sha256 of the password; nil if none
+
+	// Players
+	Sessions  map[int]*Session // seat -> session
+	PlayerIDs []string         // seat -> session id ("" if empty)
+	watchers  map[*Session]bool
+
+	// Lobby: ready flags and pending swap requests (seat -> wanted seat)
+	Ready   map[int]bool
+	swapReq map[int]int
+
+	// seat -> secret that lets a reconnecting player take the seat back
+	seatTokens map[int]string
+	// seats whose player left for good; their bots go when the hand ends
+	leavers map[int]bool
 
 	// Hands are private: seat -> cards
 	Hands map[int][]Card
@@ -42,6 +109,12 @@ type Room struct {
 	Turn     int
 	HandOver bool
 
+	// Completed tricks this hand
+	Tricks      map[int]int // seat -> tricks taken
+	LastTrick   []Card
+	LastTrickBy []int
+	LastWinner  int
+
 	// Round meta
 	Trump   string // "", hearts/spades/clubs/diamonds
 	Started bool
@@ -67,37 +140,112 @@ type Room struct {
 	// Weli holder after cut (if bottom card was weli)
 	WeliKeptBy int // -1 if none
 
+	rng *rand.Rand // deals, cuts and random seating
+
 	// Piles
 	stock          []Card // talon (remaining deck after deal)
 	swamp          []Card // face-down discards; shuffled when first used
 	swampShuffled  bool
-	exchangeMax    int // per current seats; 3 for 3p
+	taken          []Card // completed tricks
+	out            []Card // hands of players who left mid-hand
+	exchangeMax    int    // per current seats; 3 for 3p
 	exchangeClosed bool
+
+	// Turn timer for the acting seat (see timers.go)
+	Deadline  time.Time // zero when no timer runs
+	turnTimer *time.Timer
+	timerKey  string
+
+	// Time banks (see clock.go)
+	Clocks     map[int]time.Duration // seat -> bank, as of clockSince for clockSeat
+	clockSeat  int                   // seat whose bank is running; -1 if none
+	clockSince time.Time
+	clockKey   string
+	flagTimer  *time.Timer
+	flagged    map[int]bool // seats already penalised this hand
+
+	// Running match score per seat; counts down, lower is better (see
+	// score.go)
+	Scores map[int]int
+
+	// What happened this hand, in order (see history.go), how much of it
+	// persist has stored, and the finished hand's result until it is
+	History []HistoryEntry
+	logged  int
+	result  *store.Result
+
+	// The room's goroutine (see actor.go): calls to run, closed once
+	// removed, and what the hub reads without asking
+	inbox   chan func()
+	quit    chan struct{}
+	closed  bool
+	summary atomic.Pointer[roomSummary]
 }
 
+// A Client is one live connection. Who is playing, and where, lives on
+// its session (see session.go).
 type Client struct {
-	hub    *Hub
-	conn   *websocket.Conn
-	send   chan []byte
-	id     string
-	name   string
-	roomID string
-	seat   int
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	sess *Session
 }
 
 type Hub struct {
 	allowOrigins map[string]bool
+	auth         AuthConfig
+	store        store.Store
+
+	// Clustering (see cluster.go): the backplane shared with other
+	// instances, this one's identity there, their public tables and the
+	// claims and releases waiting to be sent
+	bp        backplane.Backplane
+	self      backplane.Instance
+	claimTTL  time.Duration
+	remoteMu  sync.Mutex
+	remote    []roomInfo
+	claimsMu  sync.Mutex
+	claims    []func(context.Context)
+	claimBusy bool
 
 	clientsMu sync.RWMutex
 	clients   map[*Client]struct{}
 
+	sessionsMu sync.Mutex
+	sessions   map[string]*Session // id -> session with live connections
+
+	// roomsMu guards the map only; each room runs on its own goroutine
+	// (see actor.go)
 	roomsMu sync.RWMutex
 	rooms   map[string]*Room
 
-	namesMu sync.RWMutex
-	names   map[string]string // clientID -> display name
+	namesMu   sync.RWMutex
+	names     map[string]string    // clientID -> display name
+	namesSeen map[string]time.Time // clientID -> last set_name/disconnect
+
+	reaped janitorCounters
+
+	botDelay time.Duration // pause before a bot acts, so humans can follow
+
+	// Maintenance (see admin.go, shutdown.go): draining refuses new tables
+	// and hands, stopped ignores every action and leaves rooms alone as
+	// sockets close
+	draining atomic.Bool
+	stopped  atomic.Bool
+
+	// Debug mode: check invariants after every change (see invariants.go)
+	debug       atomic.Bool
+	violations  atomic.Int64
+	onViolation func(msg string)
+
+	metrics *hubMetrics // see metrics.go
 }
 
+const (
+	inviteCodeLen    = 6
+	defaultInviteTTL = time.Hour
+)
+
 // ----------------------------- Hub lifecycle -----------------------------
 
 func NewHub(allowList []string) *Hub {
@@ -108,12 +256,21 @@ func NewHub(allowList []string) *Hub {
 			allow[o] = true
 		}
 	}
-	return &Hub{
+	h := &Hub{
 		allowOrigins: allow,
 		clients:      make(map[*Client]struct{}),
+		sessions:     make(map[string]*Session),
 		rooms:        make(map[string]*Room),
 		names:        make(map[string]string),
+		namesSeen:    make(map[string]time.Time),
+		botDelay:     defaultBotDelay,
+		store:        store.NewMemory(),
+		bp:           backplane.NewLoopback(),
+		self:         backplane.Instance{ID: randID()},
+		claimTTL:     3 * defaultClusterInterval,
 	}
+	h.metrics = newHubMetrics(h)
+	return h
 }
 
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
@@ -122,24 +279,54 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden origin", http.StatusForbidden)
 		return
 	}
+	claims, authed, err := h.authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		// dev-friendly; tighten via WS_ALLOW_ORIGINS for prod
 		InsecureSkipVerify: true,
+		Subprotocols:       []string{tokenSubprotocol},
 	})
 	if err != nil {
 		log.Printf("ws accept error: %v", err)
 		return
 	}
 
+	id := ""
+	if authed {
+		id = claims.Sub
+	}
+	sess := h.sessionFor(id)
 	client := &Client{
 		hub:  h,
 		conn: c,
 		send: make(chan []byte, 32),
-		id:   randID(),
-		seat: -1,
+	}
+	sess.attach(client)
+	if authed {
+		sess.setIdentity(claims.Name, true, claims.Guest)
+		name := claims.Name
+		if name == "" {
+			name = h.storedName(sess.id)
+		}
+		if name != "" {
+			h.namesMu.Lock()
+			h.names[sess.id] = name
+			h.namesSeen[sess.id] = time.Now()
+			h.namesMu.Unlock()
+		}
+		h.saveUser(sess, name)
 	}
 	h.addClient(client)
+	name, signedIn, _ := sess.identity()
+	h.send(client, "welcome", map[string]any{"id": sess.id, "name": name, "auth": signedIn})
 	h.sendRoomsList(client) // greet
+	h.catchUp(client)
+	if authed {
+		h.reclaimSeats(sess)
+	}
 
 	go client.writePump()
 	client.readPump()
@@ -167,20 +354,39 @@ func (c *Client) readPump() {
 	for {
 		_, data, err := c.conn.Read(context.Background())
 		if err != nil {
+			c.hub.metrics.disconnects.Inc(c.hub.disconnectReason(err))
 			return
 		}
-		var env struct {
-			T string                 `json:"t"`
-			M map[string]interface{} `json:"m"`
-		}
-		if err := json.Unmarshal(data, &env); err != nil {
-			continue
-		}
-		if env.T == "ping" {
-			continue
-		}
-		c.hub.handleMessage(c, env.T, env.M)
+		c.hub.handleRaw(c, data)
+	}
+}
+
+// handleRaw decodes one {"t","m"} envelope and dispatches it. Bots feed
+// their actions through here too, so they are parsed exactly like a
+// browser's.
+func (h *Hub) handleRaw(c *Client, data []byte) {
+	var env struct {
+		T string                 `json:"t"`
+		M map[string]interface{} `json:"m"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return
+	}
+	typ := msgType(env.T)
+	// bots come in the same way but aren't client traffic
+	bot := c.sess.bot != nil
+	if bot {
+		h.metrics.botActions.Inc(typ)
+	} else {
+		h.metrics.messagesIn.Inc(typ)
+	}
+	if env.T == "ping" || h.stopped.Load() {
+		return
+	}
+	if !bot {
+		defer h.observeAction(typ, time.Now())
 	}
+	h.handleMessage(c, env.T, env.M)
 }
 
 // ----------------------------- Helpers -----------------------------
@@ -197,92 +403,220 @@ func (h *Hub) addClient(c *Client) {
 	h.clientsMu.Unlock()
 }
 
+// removeClient drops a closed connection. The player only leaves their
+// table once the last of their session's connections is gone.
 func (h *Hub) removeClient(c *Client) {
-	if c.roomID != "" && c.seat >= 0 {
-		h.roomsMu.Lock()
-		if room, ok := h.rooms[c.roomID]; ok {
-			if room.Conns[c.seat] == c {
-				room.Conns[c.seat] = nil
-				room.PlayerIDs[c.seat] = ""
-				delete(room.Hands, c.seat)
-				allEmpty := true
-				for _, pid := range room.PlayerIDs {
-					if pid != "" {
-						allEmpty = false
-						break
-					}
-				}
-				if allEmpty {
-					delete(h.rooms, room.ID)
-				}
-			}
-		}
-		h.roomsMu.Unlock()
-	}
 	h.clientsMu.Lock()
 	delete(h.clients, c)
 	h.clientsMu.Unlock()
+	s := c.sess
+	if s.detach(c) > 0 || h.stopped.Load() {
+		return
+	}
+	h.dropSession(s)
+	for id, seat := range s.tables() {
+		room := h.room(id)
+		if room == nil {
+			continue
+		}
+		room.do(func() {
+			if seat < 0 {
+				delete(room.watchers, s)
+				s.unwatch(id)
+			} else {
+				h.leaveRoom(s, room, true)
+			}
+		})
+	}
+	for _, room := range h.allRooms() {
+		if room.summary.Load().host != s.id {
+			continue
+		}
+		room.do(func() {
+			if room.Host == s.id {
+				// created a room but never sat down
+				room.Host = ""
+				passHost(room, room.Seats-1)
+			}
+		})
+	}
+	h.namesMu.Lock()
+	if _, ok := h.names[s.id]; ok {
+		h.namesSeen[s.id] = time.Now()
+	}
+	h.namesMu.Unlock()
+}
+
+// leaveRoom frees s's seat at room and tells the rest of the table. Rooms
+// left with nobody seated are deleted. Runs on room's goroutine.
+func (h *Hub) leaveRoom(s *Session, room *Room, hold bool) {
+	seat := s.seatIn(room.ID)
+	if seat < 0 {
+		return
+	}
+	s.unseat(room.ID)
+	if room.Sessions[seat] != s {
+		return
+	}
+	outcome := h.abandonSeat(room, seat, hold)
+	if humansSeated(room) {
+		h.announceLeave(room, seat, outcome, false)
+		h.broadcastState(room)
+		return
+	}
+	h.removeRoom(room)
+	h.dropSnapshot(room.ID)
+	h.releaseRoom(room.ID, room.InviteCode)
+	h.broadcastRoom(room, "room_closed", map[string]any{"room": room.ID, "reason": "empty"})
 }
 
 func (h *Hub) send(c *Client, t string, m any) {
 	env := map[string]any{"t": t, "m": m}
 	b, _ := json.Marshal(env)
-	select {
-	case c.send <- b:
-	default:
-	}
+	c.push(t, b)
 }
 
 func (h *Hub) broadcastRoom(room *Room, t string, m any) {
 	env := map[string]any{"t": t, "m": m}
 	b, _ := json.Marshal(env)
-	for _, cl := range room.Conns {
-		if cl == nil {
-			continue
+	for _, s := range room.Sessions {
+		if s != nil {
+			s.deliver(t, b)
 		}
-		select {
-		case cl.send <- b:
-		default:
+	}
+	for s := range room.watchers {
+		s.deliver(t, b)
+	}
+}
+
+// roomInfo is one table in the lobby list.
+type roomInfo struct {
+	ID       string `json:"id"`
+	Seats    int    `json:"seats"`
+	Occupied int    `json:"occupied"`
+	Started  bool   `json:"started"`
+	Password bool   `json:"password"`
+	Locked   bool   `json:"locked"`
+}
+
+// roomsList is the lobby: this hub's public tables and those of the other
+// instances.
+func (h *Hub) roomsList() map[string]any {
+	list := h.localTables()
+	h.remoteMu.Lock()
+	list = append(list, h.remote...)
+	h.remoteMu.Unlock()
+	return map[string]any{"list": list}
+}
+
+func (h *Hub) localTables() []roomInfo {
+	rooms := h.allRooms()
+	list := make([]roomInfo, 0, len(rooms))
+	for _, r := range rooms {
+		if sum := r.summary.Load(); !sum.private {
+			list = append(list, sum.info)
 		}
 	}
+	return list
 }
 
 func (h *Hub) sendRoomsList(to *Client) {
-	type roomInfo struct {
-		ID       string `json:"id"`
-		Seats    int    `json:"seats"`
-		Occupied int    `json:"occupied"`
-		Started  bool   `json:"started"`
-	}
-	h.roomsMu.RLock()
-	list := make([]roomInfo, 0, len(h.rooms))
-	for _, r := range h.rooms {
-		occ := 0
-		for _, pid := range r.PlayerIDs {
-			if pid != "" {
-				occ++
-			}
-		}
-		list = append(list, roomInfo{ID: r.ID, Seats: r.Seats, Occupied: occ, Started: r.Started})
+	h.send(to, "rooms", h.roomsList())
+}
+
+// broadcastRoomsList pushes the lobby list to every connected client.
+func (h *Hub) broadcastRoomsList() {
+	list := h.roomsList()
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	for c := range h.clients {
+		h.send(c, "rooms", list)
 	}
-	h.roomsMu.RUnlock()
-	h.send(to, "rooms", map[string]any{"list": list})
 }
 
 // ----------------------------- Message handling -----------------------------
 
+// roomMessages are the messages about one room, handled on its goroutine
+// by handleRoomMessage, with the error a client gets when the room doesn't
+// exist ("" for none).
+var roomMessages = map[string]string{
+	"new_invite":    "not seated at this table",
+	"join_table":    "room not found",
+	"take_seat":     "",
+	"ready":         "",
+	"start_game":    "room not found",
+	"leave_table":   "",
+	"watch_table":   "room not found",
+	"unwatch_table": "",
+	"rejoin":        "nothing to rejoin",
+	"kick":          "room not found",
+	"add_bot":       "room not found",
+	"lock_table":    "room not found",
+	"transfer_host": "room not found",
+	"set_rules":     "room not found",
+	"chat":          "",
+	"new_hand":      "room not found",
+	"start_choice":  "",
+	"cut_proceed":   "",
+	"pass":          "",
+	"bid":           "",
+	"pick_trump":    "",
+	"stay_home":     "",
+	"exchange":      "",
+	"exchange_done": "",
+	"hint":          "not seated at this table",
+	"move":          "",
+}
+
+// seatMessages are the game actions. They always act for the sender's own
+// seat at the room; a "seat" in the message is ignored.
+var seatMessages = map[string]bool{
+	"start_choice":  true,
+	"cut_proceed":   true,
+	"pass":          true,
+	"bid":           true,
+	"pick_trump":    true,
+	"stay_home":     true,
+	"exchange":      true,
+	"exchange_done": true,
+	"hint":          true,
+	"move":          true,
+}
+
+// seatOf returns the seat s plays at room, -1 if it has none there. A
+// player whose seat a stand-in bot holds has none until they rejoin.
+func seatOf(room *Room, s *Session) int {
+	seat := s.seatIn(room.ID)
+	if seat < 0 || room.Sessions[seat] != s {
+		return -1
+	}
+	return seat
+}
+
 func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 	switch typ {
 
 	case "set_name":
-		name := strings.TrimSpace(fmt.Sprint(m["name"]))
+		name := cleanName(fmt.Sprint(m["name"]))
+		if own, authed, guest := c.sess.identity(); authed && !guest && own != "" {
+			h.send(c, "error", map[string]any{"msg": "your name comes from your account"})
+			return
+		}
 		if name != "" {
 			h.namesMu.Lock()
-			h.names[c.id] = name
+			h.names[c.sess.id] = name
+			h.namesSeen[c.sess.id] = time.Now()
 			h.namesMu.Unlock()
+			h.issueGuestToken(c, name)
+			h.saveUser(c.sess, name)
 		}
+		return
 
 	case "create_table":
+		if h.draining.Load() {
+			h.send(c, "error", map[string]any{"msg": drainNoTables})
+			return
+		}
 		seats := 3
 		if v, ok := m["seats"].(float64); ok {
 			seats = int(v)
@@ -291,139 +625,414 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 			seats = 2
 		}
 		id := randID()
-		room := &Room{
-			ID:          id,
-			Game:        "mulatschak",
-			Seats:       seats,
-			Conns:       make(map[int]*Client, seats),
-			PlayerIDs:   make([]string, seats),
-			Hands:       make(map[int][]Card, seats),
-			Dealer:      -1,
-			FirstBidder: 0,
-			Phase:       "",
-			Actor:       -1,
-			BestBy:      -1,
-			Passed:      make(map[int]bool),
-			WeliKeptBy:  -1,
-			Stayed:      make(map[int]bool),
-			Acted:       make(map[int]bool),
-		}
-		h.roomsMu.Lock()
-		h.rooms[id] = room
-		h.roomsMu.Unlock()
-		h.send(c, "created", map[string]any{"room": id})
+		room := newRoom(id, seats, c.sess.id, time.Now().UnixNano())
+		if rules, ok := m["rules"].(map[string]interface{}); ok {
+			room.Rules.apply(rules)
+		}
+		room.Private, _ = m["private"].(bool)
+		if pw, _ := m["password"].(string); pw != "" {
+			room.passHash = hashPassword(pw)
+		}
+		if invite, _ := m["invite"].(bool); invite || room.Private {
+			room.newInvite(inviteTTL(m))
+		}
+		created := map[string]any{"room": id, "private": room.Private}
+		if room.InviteCode != "" {
+			created["code"] = room.InviteCode
+			created["codeExpires"] = room.InviteExpires.Unix()
+		}
+		code := room.InviteCode
+		h.addRoom(room)
+		h.claimRoom(id, code)
+		h.send(c, "created", created)
 		h.sendRoomsList(c)
+		return
+	}
+
+	notFound, ok := roomMessages[typ]
+	if !ok {
+		return
+	}
+	roomID := fmt.Sprint(m["room"])
+	code, _ := m["code"].(string)
+	var room *Room
+	if code != "" && (typ == "join_table" || typ == "watch_table") {
+		room = h.roomByCode(code)
+	} else {
+		room = h.room(roomID)
+	}
+	if room != nil && room.do(func() { h.handleRoomMessage(c, room, typ, m) }) {
+		return
+	}
+	// no such room, or it closed in the meantime
+	switch typ {
+	case "join_table", "watch_table", "rejoin":
+		if h.redirect(c, roomID, code) {
+			return
+		}
+	case "leave_table":
+		h.sendSession(c.sess, "rooms", h.roomsList())
+	case "unwatch_table":
+		c.sess.unwatch(roomID)
+	}
+	if notFound != "" {
+		h.send(c, "error", map[string]any{"room": roomID, "msg": notFound})
+	}
+}
+
+// handleRoomMessage handles one of roomMessages. Runs on room's goroutine.
+func (h *Hub) handleRoomMessage(c *Client, room *Room, typ string, m map[string]interface{}) {
+	roomID := room.ID
+	room.lastActive = time.Now()
+	seat := -1
+	if seatMessages[typ] {
+		if seat = seatOf(room, c.sess); seat < 0 {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "not seated at this table"})
+			return
+		}
+	}
+	switch typ {
+
+	case "new_invite":
+		if c.sess.seatIn(roomID) < 0 {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "not seated at this table"})
+			return
+		}
+		room.newInvite(inviteTTL(m))
+		h.claimRoom(roomID, room.InviteCode)
+		h.send(c, "invite", map[string]any{"room": roomID, "code": room.InviteCode, "codeExpires": room.InviteExpires.Unix()})
 
 	case "join_table":
-		roomID := fmt.Sprint(m["room"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room == nil {
-			h.roomsMu.Unlock()
-			h.send(c, "error", map[string]any{"msg": "room not found"})
+		code, _ := m["code"].(string)
+		password, _ := m["password"].(string)
+		if err := room.checkAccess(code, password, time.Now()); err != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": err})
+			return
+		}
+		if room.Locked {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "room locked"})
+			return
+		}
+		if room.banned[c.sess.id] {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "removed from this table by the host"})
+			return
+		}
+		if c.sess.seatIn(roomID) >= 0 {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "already seated at this table"})
+			return
+		}
+		if room.Phase != "" {
+			// a seat freed mid-hand has no cards: watch until the hand ends
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "cannot join during a hand"})
+			room.watchers[c.sess] = true
+			c.sess.watch(roomID)
+			c.sess.deliver("state", h.stateMsg(room, -1))
 			return
 		}
 		seat := -1
-		for i := 0; i < room.Seats; i++ {
-			if room.PlayerIDs[i] == "" && room.Conns[i] == nil {
-				seat = i
-				break
+		if want, ok := m["seat"].(float64); ok {
+			seat = int(want)
+			if seat < 0 || seat >= room.Seats || room.PlayerIDs[seat] != "" || room.Sessions[seat] != nil {
+				h.send(c, "error", map[string]any{"room": roomID, "msg": "seat taken"})
+				return
+			}
+		} else {
+			for i := 0; i < room.Seats; i++ {
+				if room.PlayerIDs[i] == "" && room.Sessions[i] == nil {
+					seat = i
+					break
+				}
 			}
 		}
 		if seat == -1 {
-			h.roomsMu.Unlock()
-			h.send(c, "error", map[string]any{"msg": "room full"})
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "room full"})
 			return
 		}
-		room.PlayerIDs[seat] = c.id
-		room.Conns[seat] = c
-		c.roomID = roomID
-		c.seat = seat
-		h.roomsMu.Unlock()
-
+		room.PlayerIDs[seat] = c.sess.id
+		room.Sessions[seat] = c.sess
+		room.seatTokens[seat] = randID()
+		c.sess.sit(roomID, seat)
+		delete(room.watchers, c.sess)
+		if room.Host == "" {
+			room.Host = c.sess.id
+		}
+		room.publish()
 		h.sendRoomsList(c)
-		h.sendStateTo(c, room)
+		h.broadcastState(room)
 
-		h.roomsMu.RLock()
-		full := true
-		for _, pid := range room.PlayerIDs {
-			if pid == "" {
-				full = false
-				break
-			}
+	// ----- seating / ready check -----
+
+	case "take_seat":
+		want := toInt(m["seat"])
+		mine := c.sess.seatIn(roomID)
+		if mine < 0 {
+			return
 		}
-		h.roomsMu.RUnlock()
-		if full && !room.Started && room.Dealer == -1 {
-			h.startHand(room)
+		if room.Phase != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "cannot change seats during a hand"})
+			return
+		}
+		if want < 0 || want >= room.Seats || want == mine {
+			return
+		}
+		if room.PlayerIDs[want] == "" && room.Sessions[want] == nil {
+			swapSeats(room, mine, want)
+		} else if room.swapReq[want] == mine {
+			// the other player asked for our seat already: swap
+			swapSeats(room, mine, want)
+		} else {
+			room.swapReq[mine] = want
+		}
+		h.broadcastState(room)
+
+	case "ready":
+		mine := c.sess.seatIn(roomID)
+		if mine < 0 {
+			return
+		}
+		if v, ok := m["ready"].(bool); ok {
+			room.Ready[mine] = v
+		} else {
+			room.Ready[mine] = !room.Ready[mine]
+		}
+		if room.Dealer == -1 && room.Phase == "" && roomFull(room) && allReady(room) && !h.draining.Load() {
+			h.startMatch(room)
 		} else {
 			h.broadcastState(room)
 		}
 
+	case "start_game":
+		var errMsg string
+		switch {
+		case room.Host != c.sess.id:
+			errMsg = "only the host can do that"
+		case room.Dealer != -1 || room.Phase != "":
+			errMsg = "already started"
+		case !roomFull(room):
+			errMsg = "table not full"
+		case h.draining.Load():
+			errMsg = drainNoTables
+		}
+		if errMsg != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": errMsg})
+			return
+		}
+		h.startMatch(room)
+
 	case "leave_table":
-		if c.roomID == "" {
+		h.leaveRoom(c.sess, room, false)
+		room.publish()
+		h.sendSession(c.sess, "rooms", h.roomsList())
+
+	case "watch_table":
+		code, _ := m["code"].(string)
+		password, _ := m["password"].(string)
+		var errMsg string
+		switch {
+		case room.banned[c.sess.id]:
+			errMsg = "removed from this table by the host"
+		case c.sess.seatIn(roomID) >= 0:
+			errMsg = "already seated at this table"
+		default:
+			errMsg = room.checkAccess(code, password, time.Now())
+		}
+		if errMsg != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": errMsg})
 			return
 		}
-		h.removeClient(c)
+		room.watchers[c.sess] = true
+		c.sess.watch(roomID)
+		c.sess.deliver("state", h.stateMsg(room, -1))
 
-	case "chat":
-		roomID := fmt.Sprint(m["room"])
-		text := strings.TrimSpace(fmt.Sprint(m["text"]))
-		if text == "" {
+	case "unwatch_table":
+		delete(room.watchers, c.sess)
+		c.sess.unwatch(roomID)
+
+	case "rejoin":
+		token, _ := m["token"].(string)
+		seat := -1
+		if token != "" && c.sess.seatIn(roomID) < 0 {
+			for s, t := range room.seatTokens {
+				if t == token && (isBotSeat(room, s) || reserved(room, s)) {
+					seat = s
+				}
+			}
+		}
+		if seat == -1 && c.sess.signedIn() && c.sess.seatIn(roomID) < 0 {
+			// a signed-in player is known by id; no seat token needed
+			for s, pid := range room.PlayerIDs {
+				if pid == c.sess.id && (isBotSeat(room, s) || reserved(room, s)) {
+					seat = s
+				}
+			}
+		}
+		if seat == -1 {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "nothing to rejoin"})
 			return
 		}
-		h.roomsMu.RLock()
-		room := h.rooms[roomID]
-		h.roomsMu.RUnlock()
-		if room == nil {
+		h.takeBack(c.sess, room, seat)
+
+	// ----- host controls -----
+
+	case "kick":
+		seat := toInt(m["seat"])
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		if seat < 0 || seat >= room.Seats || room.PlayerIDs[seat] == "" || seat == c.sess.seatIn(roomID) {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "cannot kick that seat"})
+			return
+		}
+		target := room.Sessions[seat]
+		if isBotSeat(room, seat) {
+			target = nil
+		} else {
+			room.banned[room.PlayerIDs[seat]] = true
+		}
+		outcome := h.abandonSeat(room, seat, false)
+		if target != nil {
+			target.unseat(roomID)
+			room.publish()
+			h.sendSession(target, "kicked", map[string]any{"room": roomID})
+			h.sendSession(target, "rooms", h.roomsList())
+		}
+		h.announceLeave(room, seat, outcome, true)
+		h.broadcastState(room)
+
+	case "add_bot":
+		kind, _ := m["kind"].(string)
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		seat := -1
+		if want, ok := m["seat"].(float64); ok {
+			seat = int(want)
+		} else {
+			for i := 0; i < room.Seats; i++ {
+				if room.PlayerIDs[i] == "" {
+					seat = i
+					break
+				}
+			}
+		}
+		var errMsg string
+		switch {
+		case room.Phase != "":
+			errMsg = "cannot add bots during a hand"
+		case seat < 0 || seat >= room.Seats || room.PlayerIDs[seat] != "" || room.Sessions[seat] != nil:
+			errMsg = "no free seat"
+		}
+		if errMsg != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": errMsg})
+			return
+		}
+		bc := h.newBotSession(kind)
+		room.PlayerIDs[seat] = bc.id
+		room.Sessions[seat] = bc
+		room.Ready[seat] = true
+		bc.sit(roomID, seat)
+		h.namesMu.Lock()
+		h.names[bc.id] = fmt.Sprintf("Bot %d", seat+1)
+		h.namesMu.Unlock()
+		if room.Dealer == -1 && roomFull(room) && allReady(room) && !h.draining.Load() {
+			h.startMatch(room)
+		} else {
+			h.broadcastState(room)
+		}
+
+	case "lock_table":
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		if v, ok := m["locked"].(bool); ok {
+			room.Locked = v
+		} else {
+			room.Locked = !room.Locked
+		}
+		h.broadcastState(room)
+
+	case "transfer_host":
+		seat := toInt(m["seat"])
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		if seat < 0 || seat >= room.Seats || room.PlayerIDs[seat] == "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "seat is empty"})
+			return
+		}
+		room.Host = room.PlayerIDs[seat]
+		h.broadcastState(room)
+
+	case "set_rules":
+		rules, _ := m["rules"].(map[string]interface{})
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		if room.Phase != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "rules can only change between hands"})
+			return
+		}
+		room.Rules.apply(rules)
+		h.broadcastState(room)
+
+	case "chat":
+		text := strings.TrimSpace(fmt.Sprint(m["text"]))
+		if text == "" || !c.sess.member(roomID) {
 			return
 		}
 		h.namesMu.RLock()
-		name := h.names[c.id]
+		name := h.names[c.sess.id]
 		h.namesMu.RUnlock()
 		h.broadcastRoom(room, "chat", map[string]any{
 			"room":      roomID,
-			"from":      c.id,
+			"from":      c.sess.id,
 			"from_name": name,
 			"text":      text,
 		})
 
 	case "new_hand":
-		roomID := fmt.Sprint(m["room"])
-		h.roomsMu.RLock()
-		room := h.rooms[roomID]
-		h.roomsMu.RUnlock()
-		if room == nil {
+		if !h.requireHost(c, roomID, room) {
+			return
+		}
+		var errMsg string
+		switch {
+		case room.Phase != "":
+			errMsg = "hand in progress"
+		case !roomFull(room):
+			errMsg = "table not full"
+		case h.draining.Load():
+			errMsg = drainNoHands
+		}
+		if errMsg != "" {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": errMsg})
 			return
 		}
-		h.startHand(room)
+		if room.Dealer == -1 {
+			h.startMatch(room)
+		} else {
+			h.startHand(room)
+		}
 
 	// ----- start / cut / bidding -----
 
 	case "start_choice":
-		roomID := fmt.Sprint(m["room"])
 		choice := strings.TrimSpace(fmt.Sprint(m["choice"])) // "cut" or "knock"
-		seat := toInt(m["seat"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "start" && seat == room.FirstBidder {
+		if room.Phase == "start" && seat == room.FirstBidder {
 			if choice == "knock" {
 				room.RoundDouble = true
+			} else {
+				choice = "cut"
 			}
+			logAction(room, seat, "start_choice", choice)
 			performCut(room)
 			room.Phase = "cut"
 			room.Actor = room.FirstBidder
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	case "cut_proceed":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "cut" && seat == room.FirstBidder {
+		if room.Phase == "cut" && seat == room.FirstBidder {
+			logAction(room, seat, "cut_proceed", "")
 			deal(room)
 			room.Phase = "bidding"
 			room.Actor = room.FirstBidder
@@ -433,18 +1042,16 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 			room.HasCutPeek = false
 			room.CutPeek = Card{}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	case "pass":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "bidding" && !room.Passed[seat] {
+		if room.Phase == "bidding" && seat == room.Actor && !room.Passed[seat] {
+			if room.BestBy == -1 && countActiveBidders(room) == 1 {
+				h.send(c, "error", map[string]any{"room": roomID, "msg": "everyone else passed: you must bid"})
+				return
+			}
 			room.Passed[seat] = true
+			logAction(room, seat, "pass", "")
 			// advance actor
 			adv := nextSeat(room, room.Actor)
 			for i := 0; i < room.Seats; i++ {
@@ -454,111 +1061,64 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 				adv = nextSeat(room, adv)
 			}
 			room.Actor = adv
-
-			// end condition: only one active & we have a bid
-			active := 0
-			for s := 0; s < room.Seats; s++ {
-				if room.PlayerIDs[s] != "" && !room.Passed[s] {
-					active++
-				}
-			}
-			if active == 1 && room.BestBy != -1 {
-				room.Actor = room.BestBy
-				if room.BestBid == 1 {
-					room.Trump = "hearts" // auto-hearts
-					startExchange(room)   // >>> go to EXCHANGE <<<
-				} else {
-					room.Phase = "pick_trump"
-				}
-			}
-		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
+			endBiddingIfDone(room)
 		}
+		h.broadcastState(room)
 
 	case "bid":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
 		bid := toInt(m["bid"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "bidding" && seat == room.Actor {
+		if room.Phase == "bidding" && seat == room.Actor {
 			if bid >= 1 && bid <= 5 && bid > room.BestBid {
 				room.BestBid = bid
 				room.BestBy = seat
+				logAction(room, seat, "bid", fmt.Sprint(bid))
 				room.Actor = nextActiveBidder(room, seat)
+				endBiddingIfDone(room)
 			}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	case "pick_trump":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
 		tr := strings.TrimSpace(fmt.Sprint(m["trump"]))
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "pick_trump" && seat == room.BestBy {
+		if room.Phase == "pick_trump" && seat == room.BestBy {
 			if tr == "hearts" || tr == "spades" || tr == "clubs" || tr == "diamonds" {
 				room.Trump = tr
+				logAction(room, seat, "pick_trump", tr)
 				startExchange(room) // >>> go to EXCHANGE after trump <<<
 			}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	// ----- Exchange phase -----
 
 	case "stay_home":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] {
+		if room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] {
 			// declarer cannot stay home, clubs forbids stay home
 			if seat != room.BestBy && room.Trump != "clubs" {
 				room.Stayed[seat] = true
 				room.Acted[seat] = true
+				logAction(room, seat, "stay_home", "")
 				advanceExchangeOrStartPlay(room)
 			}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	case "exchange":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
 		cardsAny, _ := m["cards"].([]interface{})
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] && !room.Stayed[seat] {
+		if room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] && !room.Stayed[seat] {
 			maxN := room.exchangeMax
 			n := len(cardsAny)
+			discard, ok := pickCards(room.Hands[seat], cardsAny)
+			if !ok {
+				h.send(c, "error", map[string]any{"room": roomID, "msg": "exchange: cards must be distinct cards from your hand"})
+				return
+			}
 			if n >= 1 && n <= maxN {
 				// discards -> swamp
-				for _, v := range cardsAny {
-					cardM, _ := v.(map[string]interface{})
-					suit := strings.ToLower(fmt.Sprint(cardM["Suit"]))
-					rank := strings.ToLower(fmt.Sprint(cardM["Rank"]))
-					idx := -1
-					for i, c := range room.Hands[seat] {
-						if strings.ToLower(c.Suit) == suit && strings.ToLower(c.Rank) == rank {
-							idx = i
-							break
-						}
-					}
-					if idx >= 0 {
-						room.swamp = append(room.swamp, room.Hands[seat][idx])
-						room.Hands[seat] = append(room.Hands[seat][:idx], room.Hands[seat][idx+1:]...)
-					}
+				for _, idx := range discard {
+					room.swamp = append(room.swamp, room.Hands[seat][idx])
 				}
+				room.Hands[seat] = removeIndexes(room.Hands[seat], discard)
 				// replacements: talon first, then shuffled swamp
 				need := n
 				for need > 0 && len(room.stock) > 0 {
@@ -568,7 +1128,7 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 				}
 				if need > 0 && len(room.swamp) > 0 {
 					if !room.swampShuffled {
-						shuffle(room.swamp)
+						shuffle(room.rng, room.swamp)
 						room.swampShuffled = true
 					}
 					for need > 0 && len(room.swamp) > 0 {
@@ -578,38 +1138,39 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 					}
 				}
 				room.Acted[seat] = true
+				logAction(room, seat, "exchange", fmt.Sprintf("%d cards", n))
 				advanceExchangeOrStartPlay(room)
 			}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
-		}
+		h.broadcastState(room)
 
 	case "exchange_done": // NEW: explicitly "no exchange"
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] {
+		if room.Phase == "exchange" && seat == room.Actor && !room.Acted[seat] {
 			// neither stayed nor swapped -> just mark acted
 			room.Acted[seat] = true
+			logAction(room, seat, "exchange_done", "")
 			advanceExchangeOrStartPlay(room)
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
+		h.broadcastState(room)
+
+	case "hint":
+		if !room.Rules.Hints {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "hints are off at this table"})
+			return
 		}
+		a, ok := h.hint(room, seat)
+		if !ok {
+			h.send(c, "error", map[string]any{"room": roomID, "msg": "nothing to decide right now"})
+			return
+		}
+		logAction(room, seat, "hint", room.Phase)
+		h.send(c, "hint", hintMsg(roomID, a))
 
 	// ----- Play -----
 
 	case "move":
-		roomID := fmt.Sprint(m["room"])
-		seat := toInt(m["seat"])
 		mv, _ := m["type"].(string)
-		h.roomsMu.Lock()
-		room := h.rooms[roomID]
-		if room != nil && room.Phase == "play" && mv == "play_card" && seat == room.Turn && !room.HandOver && !room.Stayed[seat] {
+		if room.Phase == "play" && mv == "play_card" && seat == room.Turn && !room.HandOver && !room.Stayed[seat] {
 			cardM, _ := m["card"].(map[string]interface{})
 			card := Card{Suit: strings.ToLower(fmt.Sprint(cardM["Suit"])), Rank: strings.ToLower(fmt.Sprint(cardM["Rank"]))}
 			hi := -1
@@ -619,18 +1180,27 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 					break
 				}
 			}
+			if hi >= 0 && !isLegalPlay(room.Hands[seat], card, room.Lead, room.Trump) {
+				h.send(c, "error", map[string]any{"room": roomID, "msg": "illegal card: follow suit, else trump"})
+				return
+			}
 			if hi >= 0 {
+				logAction(room, seat, "play", cardName(room.Hands[seat][hi]))
 				room.Trick = append(room.Trick, room.Hands[seat][hi])
 				room.TrickBy = append(room.TrickBy, seat)
 				room.Hands[seat] = append(room.Hands[seat][:hi], room.Hands[seat][hi+1:]...)
 				if len(room.Trick) == 1 {
-					room.Lead = room.Trick[0].Suit
+					room.Lead = effSuit(room.Trick[0], room.Trump)
 				}
 				room.Turn = nextInSeat(room, seat)
 
 				if len(room.TrickBy) == countInPlayers(room) {
-					// TODO: real trick winner; placeholder = first
-					winner := room.TrickBy[0]
+					winner := trickWinner(room.Trick, room.TrickBy, room.Trump)
+					room.Tricks[winner]++
+					room.taken = append(room.taken, room.Trick...)
+					room.LastTrick = room.Trick
+					room.LastTrickBy = room.TrickBy
+					room.LastWinner = winner
 					room.Turn = winner
 					room.Trick = nil
 					room.TrickBy = nil
@@ -644,38 +1214,266 @@ func (h *Hub) handleMessage(c *Client, typ string, m map[string]interface{}) {
 						}
 					}
 					if empty {
-						room.HandOver = true
-						room.Started = false
-						room.Phase = ""
+						h.scoreHand(room)
 					}
 				}
 			}
 		}
-		h.roomsMu.Unlock()
-		if room != nil {
-			h.broadcastState(room)
+		h.broadcastState(room)
+	}
+}
+
+// newRoom builds an empty table. seed drives its shuffles and random
+// seating, so a fixed seed replays the same deals.
+func newRoom(id string, seats int, host string, seed int64) *Room {
+	now := time.Now()
+	return &Room{
+		ID:          id,
+		Game:        "mulatschak",
+		Seats:       seats,
+		Sessions:    make(map[int]*Session, seats),
+		watchers:    make(map[*Session]bool),
+		PlayerIDs:   make([]string, seats),
+		Hands:       make(map[int][]Card, seats),
+		Dealer:      -1,
+		FirstBidder: 0,
+		Phase:       "",
+		Actor:       -1,
+		BestBy:      -1,
+		Passed:      make(map[int]bool),
+		WeliKeptBy:  -1,
+		Stayed:      make(map[int]bool),
+		Acted:       make(map[int]bool),
+		Ready:       make(map[int]bool),
+		swapReq:     make(map[int]int),
+		seatTokens:  make(map[int]string),
+		leavers:     make(map[int]bool),
+		Clocks:      make(map[int]time.Duration),
+		clockSeat:   -1,
+		Scores:      make(map[int]int),
+		Rules:       defaultRules(),
+		Host:        host,
+		banned:      make(map[string]bool),
+		CreatedAt:   now,
+		lastActive:  now,
+		rng:         rand.New(rand.NewSource(seed)),
+		inbox:       make(chan func()),
+		quit:        make(chan struct{}),
+	}
+}
+
+// ----------------------------- Access control -----------------------------
+
+func hashPassword(pw string) []byte {
+	sum := sha256.Sum256([]byte(pw))
+	return sum[:]
+}
+
+func inviteTTL(m map[string]interface{}) time.Duration {
+	if v, ok := m["invite_ttl"].(float64); ok && v > 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return defaultInviteTTL
+}
+
+func (r *Room) newInvite(ttl time.Duration) {
+	r.InviteCode = util.Code(inviteCodeLen)
+	r.InviteExpires = time.Now().Add(ttl)
+}
+
+// checkAccess returns "" if the credentials let a player into r, otherwise
+// the error to show. A valid invite code always admits; otherwise the
+// password is required if one is set, and private rooms need one of the two.
+func (r *Room) checkAccess(code, password string, now time.Time) string {
+	if code != "" && r.InviteCode != "" && strings.EqualFold(code, r.InviteCode) {
+		if now.After(r.InviteExpires) {
+			return "invite expired"
+		}
+		return ""
+	}
+	if r.passHash != nil {
+		if subtle.ConstantTimeCompare(hashPassword(password), r.passHash) != 1 {
+			return "wrong password"
 		}
+		return ""
 	}
+	if r.Private {
+		return "invite required"
+	}
+	return ""
+}
+
+// ----------------------------- Seating -----------------------------
+
+func (r *RoomRules) apply(m map[string]interface{}) {
+	if v, ok := m["randomSeats"].(bool); ok {
+		r.RandomSeats = v
+	}
+	if v, ok := m["randomDealer"].(bool); ok {
+		r.RandomDealer = v
+	}
+	if v, ok := m["onLeave"].(string); ok && (v == leaveBot || v == leaveVoid || v == leaveLose) {
+		r.OnLeave = v
+	}
+	if v, ok := m["takeoverBot"].(string); ok {
+		r.TakeoverBot = v
+	}
+	if v, ok := m["turnSeconds"].(map[string]interface{}); ok {
+		ts := make(map[string]int, len(v))
+		for phase, sec := range v {
+			if n := toInt(sec); n > 0 {
+				ts[phase] = n
+			}
+		}
+		r.TurnSeconds = ts
+	}
+	if v, ok := m["timeBank"]; ok {
+		r.TimeBank = max(toInt(v), 0)
+	}
+	if v, ok := m["increment"]; ok {
+		r.Increment = max(toInt(v), 0)
+	}
+	if v, ok := m["outOfTime"].(string); ok && (v == outOfTimePenalty || v == outOfTimeForfeit) {
+		r.OutOfTime = v
+	}
+	if v, ok := m["timePenalty"]; ok {
+		r.TimePenalty = max(toInt(v), 0)
+	}
+	if v, ok := m["hints"].(bool); ok {
+		r.Hints = v
+	}
+}
+
+// swapSeats exchanges everything tied to seats a and b (either may be
+// empty), scores and time banks included, and clears their ready flags
+// and swap requests.
+func swapSeats(room *Room, a, b int) {
+	room.PlayerIDs[a], room.PlayerIDs[b] = room.PlayerIDs[b], room.PlayerIDs[a]
+	room.Sessions[a], room.Sessions[b] = room.Sessions[b], room.Sessions[a]
+	room.Hands[a], room.Hands[b] = room.Hands[b], room.Hands[a]
+	room.seatTokens[a], room.seatTokens[b] = room.seatTokens[b], room.seatTokens[a]
+	room.Scores[a], room.Scores[b] = room.Scores[b], room.Scores[a]
+	room.Clocks[a], room.Clocks[b] = room.Clocks[b], room.Clocks[a]
+	for _, s := range []int{a, b} {
+		if room.seatTokens[s] == "" {
+			delete(room.seatTokens, s)
+		}
+		if room.Sessions[s] != nil {
+			room.Sessions[s].sit(room.ID, s)
+		}
+		if len(room.Hands[s]) == 0 {
+			delete(room.Hands, s)
+		}
+		delete(room.Ready, s)
+		delete(room.swapReq, s)
+	}
+	for from, to := range room.swapReq {
+		if to == a || to == b {
+			delete(room.swapReq, from)
+		}
+	}
+}
+
+// vacateSeat empties a seat, clearing its score for whoever sits next, and
+// passes the host role on if the host sat there. Runs on the room's
+// goroutine.
+func vacateSeat(room *Room, seat int) {
+	wasHost := room.PlayerIDs[seat] != "" && room.PlayerIDs[seat] == room.Host
+	stopBot(room.Sessions[seat])
+	delete(room.seatTokens, seat)
+	room.Sessions[seat] = nil
+	room.PlayerIDs[seat] = ""
+	room.out = append(room.out, room.Hands[seat]...)
+	delete(room.Hands, seat)
+	delete(room.Scores, seat)
+	delete(room.Ready, seat)
+	delete(room.swapReq, seat)
+	if wasHost {
+		room.Host = ""
+		passHost(room, seat)
+	}
+}
+
+// passHost gives the host role to the next seated person after from.
+func passHost(room *Room, from int) {
+	for i := 1; i <= room.Seats; i++ {
+		s := (from + i) % room.Seats
+		if room.PlayerIDs[s] != "" && !isBotSeat(room, s) {
+			room.Host = room.PlayerIDs[s]
+			return
+		}
+	}
+}
+
+// requireHost reports whether c is the host of room and tells c otherwise.
+func (h *Hub) requireHost(c *Client, roomID string, room *Room) bool {
+	if room == nil {
+		h.send(c, "error", map[string]any{"room": roomID, "msg": "room not found"})
+		return false
+	}
+	if room.Host != c.sess.id {
+		h.send(c, "error", map[string]any{"room": roomID, "msg": "only the host can do that"})
+		return false
+	}
+	return true
+}
+
+func roomFull(room *Room) bool {
+	for _, pid := range room.PlayerIDs {
+		if pid == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func allReady(room *Room) bool {
+	for s, pid := range room.PlayerIDs {
+		if pid != "" && !room.Ready[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// startMatch applies the seating/dealer options and deals the first hand.
+func (h *Hub) startMatch(room *Room) {
+	if room.Rules.RandomSeats {
+		for i := room.Seats - 1; i > 0; i-- {
+			swapSeats(room, i, room.rng.Intn(i+1))
+		}
+	}
+	if room.Rules.RandomDealer {
+		// startHand rotates once, so park the dealer one seat before the pick
+		room.Dealer = (room.rng.Intn(room.Seats) + room.Seats - 1) % room.Seats
+	}
+	room.Ready = make(map[int]bool)
+	room.swapReq = make(map[int]int)
+	h.startHand(room)
 }
 
 // ----------------------------- Game flow helpers -----------------------------
 
 func (h *Hub) startHand(room *Room) {
-	h.roomsMu.Lock()
-	defer h.roomsMu.Unlock()
-
 	// rotate dealer
-	if room.Dealer == -1 {
-		room.Dealer = 0
-	} else {
-		room.Dealer = (room.Dealer + 1) % room.Seats
-	}
-	room.FirstBidder = (room.Dealer + 1) % room.Seats
+	room.Dealer = nextOccupied(room, room.Dealer)
+	resetHand(room)
+	h.broadcastState(room)
+}
+
+// resetHand clears the round and waits for the first bidder to cut or
+// knock. Runs on the room's goroutine.
+func resetHand(room *Room) {
+	room.FirstBidder = nextOccupied(room, room.Dealer)
 
 	// reset round state
 	room.Hands = make(map[int][]Card, room.Seats)
 	room.Trick = nil
 	room.TrickBy = nil
+	room.Tricks = make(map[int]int, room.Seats)
+	room.LastTrick = nil
+	room.LastTrickBy = nil
+	room.LastWinner = -1
 	room.Turn = room.FirstBidder
 	room.HandOver = false
 	room.Trump = ""
@@ -691,6 +1489,8 @@ func (h *Hub) startHand(room *Room) {
 
 	room.stock = nil
 	room.swamp = nil
+	room.taken = nil
+	room.out = nil
 	room.swampShuffled = false
 	room.exchangeClosed = false
 	room.Stayed = make(map[int]bool)
@@ -702,8 +1502,31 @@ func (h *Hub) startHand(room *Room) {
 		room.exchangeMax = 3
 	}
 
+	resetClocks(room)
+	room.History = nil
+	room.logged = 0
+	room.result = nil
 	room.Phase = "start"
-	h.broadcastState(room)
+}
+
+// endBiddingIfDone closes the auction once only the best bidder is left,
+// whether the last step was a pass or a bid after everyone else passed.
+func endBiddingIfDone(room *Room) {
+	active := 0
+	for s := 0; s < room.Seats; s++ {
+		if room.PlayerIDs[s] != "" && !room.Passed[s] {
+			active++
+		}
+	}
+	if active == 1 && room.BestBy != -1 {
+		room.Actor = room.BestBy
+		if room.BestBid == 1 {
+			room.Trump = "hearts" // auto-hearts
+			startExchange(room)   // >>> go to EXCHANGE <<<
+		} else {
+			room.Phase = "pick_trump"
+		}
+	}
 }
 
 func startExchange(room *Room) {
@@ -744,18 +1567,21 @@ func advanceExchangeOrStartPlay(room *Room) {
 
 func performCut(room *Room) {
 	deck := buildMulatschakDeck()
-	shuffle(deck)
+	shuffle(room.rng, deck)
 	if len(deck) < 2 {
 		return
 	}
-	cut := rand.Intn(len(deck)-1) + 1
+	cut := room.rng.Intn(len(deck)-1) + 1
 	bottom := deck[cut-1]
 	room.CutPeek = bottom
 	room.HasCutPeek = true
 	room.WeliKeptBy = -1
+	// the lifted packet goes under the rest, so the card the cutter saw is
+	// the bottom of the deck and, after the deal, the last card of the talon
+	deck = slices.Concat(deck[cut:], deck[:cut])
 	if isWeli(bottom) {
 		room.WeliKeptBy = room.FirstBidder
-		deck = append(deck[:cut-1], deck[cut:]...)
+		deck = deck[:len(deck)-1]
 	}
 	room.stock = deck
 }
@@ -763,13 +1589,18 @@ func performCut(room *Room) {
 func deal(room *Room) {
 	if room.stock == nil {
 		deck := buildMulatschakDeck()
-		shuffle(deck)
+		shuffle(room.rng, deck)
 		room.stock = deck
 	}
+	// a Weli cut from the bottom goes to the first bidder, who is then
+	// dealt one card less
+	if room.WeliKeptBy >= 0 {
+		room.Hands[room.WeliKeptBy] = []Card{{Suit: "diamonds", Rank: "weli"}}
+	}
 	// 5 cards to each seat
 	for i := 0; i < 5; i++ {
 		for s := 0; s < room.Seats; s++ {
-			if room.PlayerIDs[s] == "" {
+			if room.PlayerIDs[s] == "" || len(room.Hands[s]) >= 5 {
 				continue
 			}
 			if len(room.stock) == 0 {
@@ -779,22 +1610,39 @@ func deal(room *Room) {
 			room.stock = room.stock[1:]
 		}
 	}
-	if room.WeliKeptBy >= 0 {
-		hasW := false
-		for _, c := range room.Hands[room.WeliKeptBy] {
-			if isWeli(c) {
-				hasW = true
+	// IMPORTANT: keep remaining room.stock as talon for exchange
+}
+
+// pickCards finds each requested card in hand and returns their indexes;
+// ok is false if a card is missing or named twice.
+func pickCards(hand []Card, req []interface{}) (idx []int, ok bool) {
+	for _, v := range req {
+		cardM, _ := v.(map[string]interface{})
+		want := Card{Suit: fmt.Sprint(cardM["Suit"]), Rank: fmt.Sprint(cardM["Rank"])}
+		found := -1
+		for i, c := range hand {
+			if sameCard(c, want) && !containsInt(idx, i) {
+				found = i
 				break
 			}
 		}
-		if !hasW {
-			room.Hands[room.WeliKeptBy] = append(room.Hands[room.WeliKeptBy], Card{Suit: "diamonds", Rank: "weli"})
+		if found < 0 {
+			return nil, false
 		}
-		if len(room.Hands[room.WeliKeptBy]) > 5 {
-			room.Hands[room.WeliKeptBy] = room.Hands[room.WeliKeptBy][:5]
+		idx = append(idx, found)
+	}
+	return idx, true
+}
+
+// removeIndexes returns hand without the cards at idx.
+func removeIndexes(hand []Card, idx []int) []Card {
+	out := make([]Card, 0, len(hand))
+	for i, c := range hand {
+		if !containsInt(idx, i) {
+			out = append(out, c)
 		}
 	}
-	// IMPORTANT: keep remaining room.stock as talon for exchange
+	return out
 }
 
 func buildMulatschakDeck() []Card {
@@ -810,11 +1658,10 @@ func buildMulatschakDeck() []Card {
 	return deck
 }
 
-func shuffle(deck []Card) {
-	rand.Seed(time.Now().UnixNano())
+func shuffle(rng *rand.Rand, deck []Card) {
 	n := len(deck)
 	for i := n - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		deck[i], deck[j] = deck[j], deck[i]
 	}
 }
@@ -833,6 +1680,17 @@ func nextSeat(room *Room, s int) int {
 	return (s + 1) % room.Seats
 }
 
+// nextOccupied is the first seated seat after from (from may be -1).
+func nextOccupied(room *Room, from int) int {
+	for i := 1; i <= room.Seats; i++ {
+		s := (from + i + room.Seats) % room.Seats
+		if room.PlayerIDs[s] != "" {
+			return s
+		}
+	}
+	return max(from, 0)
+}
+
 func nextActiveBidder(room *Room, from int) int {
 	n := room.Seats
 	for i := 1; i <= n; i++ {
@@ -881,7 +1739,13 @@ func toInt(v interface{}) int {
 
 // ----------------------------- State sending -----------------------------
 
-func (h *Hub) sendStateTo(to *Client, r *Room) {
+func (h *Hub) sendStateTo(to *Session, r *Room) {
+	to.deliver("state", h.stateMsg(r, to.seatIn(r.ID)))
+}
+
+// stateMsg is the "state" message as seen from seat. Runs on the room's
+// goroutine.
+func (h *Hub) stateMsg(r *Room, seat int) []byte {
 	counts := make([]int, r.Seats)
 	for s := 0; s < r.Seats; s++ {
 		counts[s] = len(r.Hands[s])
@@ -900,6 +1764,20 @@ func (h *Hub) sendStateTo(to *Client, r *Room) {
 			passed = append(passed, s)
 		}
 	}
+	ready := make([]bool, r.Seats)
+	bots := make([]bool, r.Seats)
+	host := -1
+	for s := 0; s < r.Seats; s++ {
+		ready[s] = r.Ready[s]
+		bots[s] = isBotSeat(r, s)
+		if r.PlayerIDs[s] != "" && r.PlayerIDs[s] == r.Host {
+			host = s
+		}
+	}
+	swaps := make(map[string]int, len(r.swapReq))
+	for from, to := range r.swapReq {
+		swaps[fmt.Sprint(from)] = to
+	}
 	stayed := make([]int, 0, len(r.Stayed))
 	for s := range r.Stayed {
 		if r.Stayed[s] {
@@ -916,21 +1794,72 @@ func (h *Hub) sendStateTo(to *Client, r *Room) {
 		})
 	}
 
+	tricks := make([]int, r.Seats)
+	for s := 0; s < r.Seats; s++ {
+		tricks[s] = r.Tricks[s]
+	}
+	var lastTrick any
+	if len(r.LastTrick) > 0 {
+		cards := make([]map[string]any, 0, len(r.LastTrick))
+		for i, c := range r.LastTrick {
+			cards = append(cards, map[string]any{
+				"suit": strings.ToLower(c.Suit),
+				"rank": strings.ToLower(c.Rank),
+				"by":   r.LastTrickBy[i],
+			})
+		}
+		lastTrick = map[string]any{"cards": cards, "winner": r.LastWinner}
+	}
+
 	var cutPeek any
-	if r.Phase == "cut" && to.seat == r.FirstBidder && r.HasCutPeek {
+	if r.Phase == "cut" && seat == r.FirstBidder && r.HasCutPeek {
 		cutPeek = map[string]any{"suit": r.CutPeek.Suit, "rank": r.CutPeek.Rank}
 	} else {
 		cutPeek = nil
 	}
 
+	scores := make([]int, r.Seats)
+	var clocks []int64
+	if r.Rules.TimeBank > 0 {
+		clocks = make([]int64, r.Seats)
+	}
+	for s := 0; s < r.Seats; s++ {
+		scores[s] = r.Scores[s]
+		if clocks != nil {
+			clocks[s] = clockLeft(r, s).Milliseconds()
+		}
+	}
+
+	var deadline, remaining int64
+	if !r.Deadline.IsZero() {
+		deadline = r.Deadline.UnixMilli()
+		remaining = max(time.Until(r.Deadline).Milliseconds(), 0)
+	}
+
 	msg := map[string]any{
 		"t": "state",
 		"m": map[string]any{
-			"room": r.ID,
+			"room":    r.ID,
+			"private": r.Private,
+			"code":    r.InviteCode,
+			"rules":   r.Rules,
+			"host":    host,
+			"locked":  r.Locked,
+			"ready":   ready,
+			"bots":    bots,
+			"token":   r.seatTokens[seat],
+			"swaps":   swaps,
 
 			"phase": r.Phase,
 			"actor": r.Actor,
 
+			"deadline":  deadline,  // unix ms; 0 = no timer
+			"remaining": remaining, // ms left when this view was sent
+			"clocks":    clocks,    // ms per seat; null without time banks
+			"clockSeat": r.clockSeat,
+			"scores":    scores,
+			"history":   r.History,
+
 			"dealer":      r.Dealer,
 			"firstBidder": r.FirstBidder,
 			"bestBid":     r.BestBid,
@@ -945,7 +1874,9 @@ func (h *Hub) sendStateTo(to *Client, r *Room) {
 			"trump":       r.Trump,
 			"lead":        r.Lead,
 			"trick":       trick,
-			"you":         r.Hands[to.seat],
+			"tricks":      tricks,
+			"lastTrick":   lastTrick,
+			"you":         r.Hands[seat],
 			"counts":      counts,
 			"talon":       len(r.stock),
 			"swamp":       len(r.swamp),
@@ -953,21 +1884,31 @@ func (h *Hub) sendStateTo(to *Client, r *Room) {
 			"started":     r.Started,
 			"handOver":    r.HandOver,
 			"names":       names,
-			"seat":        to.seat,
+			"seat":        seat,
 		},
 	}
 	b, _ := json.Marshal(msg)
-	select {
-	case to.send <- b:
-	default:
-	}
+	return b
 }
 
+// broadcastState queues every seat's view. It runs on the room's
+// goroutine, so views from two actions can't reach a client in the wrong
+// order.
 func (h *Hub) broadcastState(r *Room) {
-	for _, cl := range r.Conns {
-		if cl == nil {
+	h.verify(r)
+	h.persist(r)
+	h.armTurnTimer(r)
+	h.runClock(r)
+	for _, s := range r.Sessions {
+		if s == nil {
 			continue
 		}
-		h.sendStateTo(cl, r)
+		h.sendStateTo(s, r)
+	}
+	if len(r.watchers) > 0 {
+		b := h.stateMsg(r, -1)
+		for s := range r.watchers {
+			s.deliver("state", b)
+		}
 	}
 }
@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Invariant checks, run after every state change when debug mode is on.
+// They catch engine bugs that would otherwise silently lose or duplicate
+// cards or leave a table waiting on a seat that can't act.
+
+// SetDebug turns the invariant checks on or off.
+func (h *Hub) SetDebug(on bool) {
+	h.debug.Store(on)
+}
+
+// InvariantViolations counts failed checks since the hub started.
+func (h *Hub) InvariantViolations() int64 {
+	return h.violations.Load()
+}
+
+// verify checks r and logs any violation with a dump of the room.
+func (h *Hub) verify(r *Room) {
+	if !h.debug.Load() {
+		return
+	}
+	problems := checkInvariants(r)
+	if len(problems) == 0 {
+		return
+	}
+	h.violations.Add(1)
+	msg := fmt.Sprintf("room %s: %s", r.ID, strings.Join(problems, "; "))
+	log.Printf("invariant violation in %s\n%s", msg, dumpRoom(r))
+	if h.onViolation != nil {
+		h.onViolation(msg)
+	}
+}
+
+// checkInvariants returns every broken rule in r. Runs on the room's goroutine.
+func checkInvariants(r *Room) []string {
+	var out []string
+	bad := func(format string, args ...any) {
+		out = append(out, fmt.Sprintf(format, args...))
+	}
+	seated := func(s int) bool {
+		return s >= 0 && s < r.Seats && r.PlayerIDs[s] != ""
+	}
+
+	if (r.Phase != "" && r.Phase != "start") || r.HandOver {
+		out = append(out, checkCards(r)...)
+	}
+
+	switch r.Phase {
+	case "start", "cut":
+		if !seated(r.FirstBidder) {
+			bad("first bidder %d is not seated", r.FirstBidder)
+		}
+	case "bidding":
+		if !seated(r.Actor) || r.Passed[r.Actor] {
+			bad("bidding actor %d is not an active bidder", r.Actor)
+		}
+		if r.BestBid < 0 || r.BestBid > 5 || (r.BestBid == 0) != (r.BestBy == -1) {
+			bad("best bid %d by %d", r.BestBid, r.BestBy)
+		}
+	case "pick_trump":
+		if !seated(r.BestBy) || r.BestBid < 2 {
+			bad("picking trump with bid %d by %d", r.BestBid, r.BestBy)
+		}
+	case "exchange":
+		if !seated(r.Actor) || r.Acted[r.Actor] {
+			bad("exchange actor %d is not waiting to exchange", r.Actor)
+		}
+	case "play":
+		if !r.HandOver && (!seated(r.Turn) || r.Stayed[r.Turn]) {
+			bad("turn %d is not a seat in play", r.Turn)
+		}
+		if len(r.Trick) != len(r.TrickBy) || len(r.Trick) >= countInPlayers(r) {
+			bad("trick has %d cards from %d seats", len(r.Trick), len(r.TrickBy))
+		}
+		lead := ""
+		if len(r.Trick) > 0 {
+			lead = effSuit(r.Trick[0], r.Trump)
+		}
+		if r.Lead != lead {
+			bad("lead %q, first card says %q", r.Lead, lead)
+		}
+		played := 0
+		for _, n := range r.Tricks {
+			played += n
+		}
+		for s := 0; s < r.Seats; s++ {
+			if !seated(s) || r.Stayed[s] {
+				continue
+			}
+			want := 5 - played
+			if containsInt(r.TrickBy, s) {
+				want--
+			}
+			if len(r.Hands[s]) != want {
+				bad("seat %d holds %d cards, want %d", s, len(r.Hands[s]), want)
+			}
+		}
+	}
+
+	switch r.Phase {
+	case "pick_trump", "exchange", "play":
+		if r.BestBid < 1 || !seated(r.BestBy) {
+			bad("no declarer in %s", r.Phase)
+		}
+		if r.Stayed[r.BestBy] {
+			bad("declarer %d stayed home", r.BestBy)
+		}
+	}
+	switch r.Phase {
+	case "exchange", "play":
+		if r.Trump == "" {
+			bad("no trump in %s", r.Phase)
+		}
+		for s, st := range r.Stayed {
+			if st && r.Trump == "clubs" {
+				bad("seat %d stayed home with clubs trump", s)
+			}
+		}
+	}
+	switch r.Phase {
+	case "bidding", "pick_trump", "exchange":
+		for s := 0; s < r.Seats; s++ {
+			if seated(s) && len(r.Hands[s]) != 5 {
+				bad("seat %d holds %d cards before play", s, len(r.Hands[s]))
+			}
+		}
+	}
+	return out
+}
+
+// checkCards verifies that every card of the deck is in exactly one place.
+func checkCards(r *Room) []string {
+	seen := make(map[string]int, 33)
+	add := func(cards []Card) {
+		for _, c := range cards {
+			seen[strings.ToLower(c.Suit)+"/"+strings.ToLower(c.Rank)]++
+		}
+	}
+	for _, h := range r.Hands {
+		add(h)
+	}
+	add(r.stock)
+	add(r.swamp)
+	add(r.Trick)
+	add(r.taken)
+	add(r.out)
+	if r.Phase == "cut" && r.WeliKeptBy >= 0 {
+		add([]Card{r.CutPeek}) // set aside until the deal
+	}
+
+	var out []string
+	for _, c := range buildMulatschakDeck() {
+		k := c.Suit + "/" + c.Rank
+		switch n := seen[k]; {
+		case n == 0:
+			out = append(out, "missing "+cardName(c))
+		case n > 1:
+			out = append(out, fmt.Sprintf("%d copies of %s", n, cardName(c)))
+		}
+		delete(seen, k)
+	}
+	for k := range seen {
+		out = append(out, "unknown card "+k)
+	}
+	return out
+}
+
+// dumpRoom renders the parts of r the checks look at. Runs on the room's goroutine.
+func dumpRoom(r *Room) []byte {
+	b, _ := json.MarshalIndent(map[string]any{
+		"phase": r.Phase, "dealer": r.Dealer, "firstBidder": r.FirstBidder,
+		"actor": r.Actor, "turn": r.Turn, "handOver": r.HandOver,
+		"bestBid": r.BestBid, "bestBy": r.BestBy, "trump": r.Trump, "lead": r.Lead,
+		"players": r.PlayerIDs, "passed": r.Passed, "acted": r.Acted, "stayed": r.Stayed,
+		"hands": r.Hands, "trick": r.Trick, "trickBy": r.TrickBy, "tricks": r.Tricks,
+		"stock": r.stock, "swamp": r.swamp, "taken": r.taken, "out": r.out,
+		"weliKeptBy": r.WeliKeptBy,
+	}, "", "  ")
+	return b
+}
@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// JanitorConfig sets how long rooms and name entries may sit unused before
+// the janitor removes them. A zero TTL disables that kind of expiry.
+type JanitorConfig struct {
+	Interval    time.Duration // time between sweeps
+	EmptyTTL    time.Duration // rooms nobody is seated in
+	IdleTTL     time.Duration // rooms with players but no actions
+	FinishedTTL time.Duration // rooms sitting between hands
+	NameTTL     time.Duration // display names of disconnected clients
+}
+
+func DefaultJanitorConfig() JanitorConfig {
+	return JanitorConfig{
+		Interval:    time.Minute,
+		EmptyTTL:    10 * time.Minute,
+		IdleTTL:     time.Hour,
+		FinishedTTL: 30 * time.Minute,
+		NameTTL:     24 * time.Hour,
+	}
+}
+
+// JanitorStats counts what the janitor has reaped since the hub started.
+type JanitorStats struct {
+	Sweeps        int64 `json:"sweeps"`
+	EmptyRooms    int64 `json:"emptyRooms"`
+	IdleRooms     int64 `json:"idleRooms"`
+	FinishedRooms int64 `json:"finishedRooms"`
+	Names         int64 `json:"names"`
+}
+
+type janitorCounters struct {
+	sweeps, empty, idle, finished, names atomic.Int64
+}
+
+func (h *Hub) JanitorStats() JanitorStats {
+	return JanitorStats{
+		Sweeps:        h.reaped.sweeps.Load(),
+		EmptyRooms:    h.reaped.empty.Load(),
+		IdleRooms:     h.reaped.idle.Load(),
+		FinishedRooms: h.reaped.finished.Load(),
+		Names:         h.reaped.names.Load(),
+	}
+}
+
+// StartJanitor sweeps the hub every cfg.Interval until ctx is done.
+func (h *Hub) StartJanitor(ctx context.Context, cfg JanitorConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	go func() {
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				h.sweep(cfg, now)
+			}
+		}
+	}()
+}
+
+func (h *Hub) sweep(cfg JanitorConfig, now time.Time) {
+	if h.stopped.Load() {
+		return
+	}
+	gone := 0
+	online := make(map[string]bool) // seated players, bots too, and live clients
+	for _, r := range h.allRooms() {
+		r.do(func() {
+			idle := now.Sub(r.lastActive)
+			occupied := humansSeated(r)
+			reason := ""
+			switch {
+			case !occupied:
+				if cfg.EmptyTTL > 0 && idle > cfg.EmptyTTL {
+					reason = "empty"
+					h.reaped.empty.Add(1)
+				}
+			case r.Phase == "" && r.Dealer != -1:
+				if cfg.FinishedTTL > 0 && idle > cfg.FinishedTTL {
+					reason = "finished"
+					h.reaped.finished.Add(1)
+				}
+			default:
+				if cfg.IdleTTL > 0 && idle > cfg.IdleTTL {
+					reason = "idle"
+					h.reaped.idle.Add(1)
+				}
+			}
+			if reason == "" {
+				for _, pid := range r.PlayerIDs {
+					online[pid] = true
+				}
+				return
+			}
+			h.removeRoom(r)
+			h.dropSnapshot(r.ID)
+			h.releaseRoom(r.ID, r.InviteCode)
+			h.broadcastRoom(r, "room_closed", map[string]any{"room": r.ID, "reason": reason})
+			gone++
+		})
+	}
+
+	reapedNames := 0
+	if cfg.NameTTL > 0 {
+		h.clientsMu.RLock()
+		for c := range h.clients {
+			online[c.sess.id] = true
+		}
+		h.clientsMu.RUnlock()
+
+		h.namesMu.Lock()
+		for id := range h.names {
+			if !online[id] && now.Sub(h.namesSeen[id]) > cfg.NameTTL {
+				delete(h.names, id)
+				delete(h.namesSeen, id)
+				reapedNames++
+			}
+		}
+		h.namesMu.Unlock()
+		h.reaped.names.Add(int64(reapedNames))
+	}
+	h.reaped.sweeps.Add(1)
+
+	if gone > 0 {
+		h.broadcastRoomsList()
+	}
+	if gone > 0 || reapedNames > 0 {
+		log.Printf("janitor: reaped %d rooms, %d names", gone, reapedNames)
+	}
+}
@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSweep runs one janitor sweep over rooms and names of every kind: only
+// the ones left unused past their TTL go.
+func TestSweep(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	cfg := JanitorConfig{EmptyTTL: time.Minute, IdleTTL: time.Hour, FinishedTTL: 10 * time.Minute, NameTTL: time.Hour}
+	now := time.Now()
+
+	// room builds a room left alone for idle; seated rooms deal a hand,
+	// finished ones play it out too
+	room := func(id string, seated, finished bool, idle time.Duration) {
+		r := newRoom(id, 3, "", 7)
+		if seated {
+			seatHeadless(h, r, id)
+		}
+		h.addRoom(r)
+		t.Cleanup(func() { r.do(func() { h.removeRoom(r) }) })
+		r.do(func() {
+			if seated {
+				h.startHand(r)
+			}
+			if finished {
+				h.forfeitHand(r, 0, "test")
+			}
+			r.lastActive = now.Add(-idle)
+		})
+	}
+	room("empty-old", false, false, 2*time.Minute)
+	room("empty-new", false, false, 30*time.Second)
+	room("playing", true, false, 30*time.Minute)
+	room("idle", true, false, 2*time.Hour)
+	room("finished-new", true, true, 5*time.Minute)
+	room("finished-old", true, true, 20*time.Minute)
+
+	h.namesMu.Lock()
+	for id, seen := range map[string]time.Duration{
+		"gone":      2 * time.Hour,    // offline too long
+		"away":      30 * time.Minute, // offline, but not for long
+		"playing-0": 2 * time.Hour,    // seated in a room that stays
+		"idle-0":    2 * time.Hour,    // seated in a room that goes
+		"online":    2 * time.Hour,    // connected
+	} {
+		h.names[id] = id
+		h.namesSeen[id] = now.Add(-seen)
+	}
+	h.namesMu.Unlock()
+	h.addClient(&Client{hub: h, send: make(chan []byte, 64), sess: newSession("online")})
+
+	h.sweep(cfg, now)
+
+	for id, kept := range map[string]bool{
+		"empty-old": false, "empty-new": true, "playing": true,
+		"idle": false, "finished-new": true, "finished-old": false,
+	} {
+		if got := h.room(id) != nil; got != kept {
+			t.Errorf("room %s kept %v, want %v", id, got, kept)
+		}
+	}
+	h.namesMu.Lock()
+	for id, kept := range map[string]bool{"gone": false, "away": true, "playing-0": true, "idle-0": false, "online": true} {
+		if _, got := h.names[id]; got != kept {
+			t.Errorf("name %s kept %v, want %v", id, got, kept)
+		}
+	}
+	h.namesMu.Unlock()
+	want := JanitorStats{Sweeps: 1, EmptyRooms: 1, IdleRooms: 1, FinishedRooms: 1, Names: 2}
+	if got := h.JanitorStats(); got != want {
+		t.Errorf("stats %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"net/http"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/metrics"
+)
+
+// hubMetrics is what /metrics reports. Counters are bumped where things
+// happen; gauges are read from the hub at scrape time.
+type hubMetrics struct {
+	reg         *metrics.Registry
+	messagesIn  *metrics.Counter
+	messagesOut *metrics.Counter
+	dropped     *metrics.Counter
+	botActions  *metrics.Counter
+	timeouts    *metrics.Counter
+	actions     *metrics.Histogram
+	hands       *metrics.Counter
+	disconnects *metrics.Counter
+}
+
+func newHubMetrics(h *Hub) *hubMetrics {
+	reg := metrics.NewRegistry()
+	reg.Gauge("cardgame_clients", "Open websocket connections.", "", func() map[string]float64 {
+		h.clientsMu.RLock()
+		defer h.clientsMu.RUnlock()
+		return map[string]float64{"": float64(len(h.clients))}
+	})
+	reg.Gauge("cardgame_rooms", "Rooms on this instance by phase.", "phase", func() map[string]float64 {
+		out := map[string]float64{}
+		for _, r := range h.allRooms() {
+			phase := r.summary.Load().phase
+			if phase == "" {
+				phase = "waiting"
+			}
+			out[phase]++
+		}
+		return out
+	})
+	reg.CounterFunc("cardgame_janitor_sweeps_total", "Janitor sweeps.", "", func() map[string]float64 {
+		return map[string]float64{"": float64(h.JanitorStats().Sweeps)}
+	})
+	reg.CounterFunc("cardgame_rooms_reaped_total", "Rooms the janitor closed, by reason.", "reason", func() map[string]float64 {
+		st := h.JanitorStats()
+		return map[string]float64{"empty": float64(st.EmptyRooms), "idle": float64(st.IdleRooms), "finished": float64(st.FinishedRooms)}
+	})
+	reg.CounterFunc("cardgame_names_reaped_total", "Display names the janitor forgot.", "", func() map[string]float64 {
+		return map[string]float64{"": float64(h.JanitorStats().Names)}
+	})
+	reg.CounterFunc("cardgame_invariant_violations_total", "Failed invariant checks (with DEBUG_INVARIANTS).", "", func() map[string]float64 {
+		return map[string]float64{"": float64(h.InvariantViolations())}
+	})
+	return &hubMetrics{
+		reg:         reg,
+		messagesIn:  reg.Counter("cardgame_messages_in_total", "Messages received from clients by type.", "type"),
+		messagesOut: reg.Counter("cardgame_messages_out_total", "Messages queued to clients by type.", "type"),
+		dropped:     reg.Counter("cardgame_messages_dropped_total", "Messages dropped because a client's send queue was full, by type.", "type"),
+		botActions:  reg.Counter("cardgame_bot_actions_total", "Messages from server-side bots by type.", "type"),
+		timeouts:    reg.Counter("cardgame_timeouts_total", "Moves made for a seat whose time ran out, by timer.", "timer"),
+		actions:     reg.Histogram("cardgame_action_seconds", "Time to handle a client message, by type.", "type", metrics.DefaultBuckets),
+		hands:       reg.Counter("cardgame_hands_completed_total", "Finished hands, played out or forfeited.", "how"),
+		disconnects: reg.Counter("cardgame_disconnects_total", "Closed connections by reason.", "reason"),
+	}
+}
+
+// MetricsHandler serves the hub's metrics in the Prometheus text format.
+func (h *Hub) MetricsHandler() http.Handler {
+	return h.metrics.reg.Handler()
+}
+
+// msgType is typ if the hub knows it, "other" if not, so clients can't
+// invent label values.
+func msgType(typ string) string {
+	if _, ok := roomMessages[typ]; ok {
+		return typ
+	}
+	switch typ {
+	case "set_name", "create_table", "ping":
+		return typ
+	}
+	return "other"
+}
+
+// observeAction records how long handling one message took.
+func (h *Hub) observeAction(typ string, start time.Time) {
+	h.metrics.actions.Observe(typ, time.Since(start).Seconds())
+}
+
+// disconnectReason names why a connection's read loop ended.
+func (h *Hub) disconnectReason(err error) string {
+	if h.stopped.Load() {
+		return "shutdown"
+	}
+	switch websocket.CloseStatus(err) {
+	case -1:
+		return "dropped" // no close frame: network error or timeout
+	case websocket.StatusNormalClosure:
+		return "normal"
+	case websocket.StatusGoingAway:
+		return "going_away"
+	}
+	return "protocol"
+}
+
+// push queues b of type t on c without blocking, dropping it if c is full.
+// Only traffic to sockets is counted, not to bots or actFor's clients.
+func (c *Client) push(t string, b []byte) {
+	select {
+	case c.send <- b:
+		if c.conn != nil {
+			c.hub.metrics.messagesOut.Inc(t)
+		}
+	default:
+		if c.conn != nil {
+			c.hub.metrics.dropped.Inc(t)
+		}
+	}
+}
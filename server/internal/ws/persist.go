@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/store"
+)
+
+// Persistence: players, hand results and each room's action log go to the
+// hub's store as they happen. The default store keeps them in memory.
+
+// SetStore sets where the hub keeps what outlives it. Call before serving.
+func (h *Hub) SetStore(s store.Store) {
+	h.store = s
+}
+
+// saveUser records s's identity and display name. A user already stored
+// keeps the time they were first seen.
+func (h *Hub) saveUser(s *Session, name string) {
+	_, authed, guest := s.identity()
+	if !authed {
+		return
+	}
+	now := time.Now()
+	u := store.User{ID: s.id, Name: name, Guest: guest, Created: now, Seen: now}
+	if old, ok, err := h.store.User(s.id); err == nil && ok && !old.Created.IsZero() {
+		u.Created = old.Created
+	}
+	err := h.store.PutUser(u)
+	if err != nil {
+		log.Printf("store: user %s: %v", s.id, err)
+	}
+}
+
+// storedName is the name s last played under, for tokens that carry none.
+func (h *Hub) storedName(id string) string {
+	u, ok, err := h.store.User(id)
+	if err != nil || !ok {
+		return ""
+	}
+	return u.Name
+}
+
+// handResult is r's hand as it ended. Runs on the room's goroutine.
+func handResult(r *Room) *store.Result {
+	result := &store.Result{
+		Room:     r.ID,
+		At:       time.Now(),
+		Players:  slices.Clone(r.PlayerIDs),
+		Tricks:   make([]int, r.Seats),
+		Scores:   make([]int, r.Seats),
+		Declarer: r.BestBy,
+		Bid:      r.BestBid,
+		Trump:    r.Trump,
+		Knocked:  r.RoundDouble,
+	}
+	for s := 0; s < r.Seats; s++ {
+		result.Tricks[s] = r.Tricks[s]
+		result.Scores[s] = r.Scores[s]
+	}
+	return result
+}
+
+// persist writes what happened at r since the last call: new history
+// entries and the result endHand booked. Runs on the room's goroutine.
+func (h *Hub) persist(r *Room) {
+	var events []store.Event
+	for _, e := range r.History[min(r.logged, len(r.History)):] {
+		events = append(events, store.Event{Room: r.ID, Seat: e.Seat, Action: e.Action, Detail: e.Detail, At: e.At})
+	}
+	r.logged = len(r.History)
+	result := r.result
+	r.result = nil
+
+	if len(events) > 0 {
+		if err := h.store.AppendEvents(events...); err != nil {
+			log.Printf("store: events for %s: %v", r.ID, err)
+		}
+	}
+	if result != nil {
+		if err := h.store.AddResult(*result); err != nil {
+			log.Printf("store: result for %s: %v", r.ID, err)
+		}
+	}
+}
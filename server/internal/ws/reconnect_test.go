@@ -0,0 +1,49 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRejoinWithToken drops an anonymous player's only connection in the
+// middle of a hand and brings them back on a new one, as a new session:
+// the seat token from their state is all it takes to get the seat back
+// from the stand-in.
+func TestRejoinWithToken(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("rejoin", 3, "p-1", 7)
+	seatHeadless(h, room, "p")
+	c := headless(h, "anon-1")
+	c.sess.sit(room.ID, 0)
+	room.PlayerIDs[0], room.Sessions[0] = c.sess.id, c.sess
+	room.seatTokens[0] = randID()
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+	room.do(func() { h.startHand(room) })
+
+	token, _ := expect(t, c, "state")["token"].(string)
+	if token == "" {
+		t.Fatal("no seat token in the state")
+	}
+	h.removeClient(c)
+	var bot bool
+	room.do(func() { bot = isBotSeat(room, 0) })
+	if !bot {
+		t.Fatal("no stand-in after the disconnect")
+	}
+
+	c2 := headless(h, "anon-2")
+	sendRaw(h, c2, "rejoin", map[string]any{"room": room.ID, "token": "wrong"})
+	expect(t, c2, "error")
+	sendRaw(h, c2, "rejoin", map[string]any{"room": room.ID, "token": token})
+	if got, _ := expect(t, c2, "state")["token"].(string); got != token {
+		t.Fatalf("state token %q, want %q", got, token)
+	}
+	var sess *Session
+	var id string
+	room.do(func() { sess, id = room.Sessions[0], room.PlayerIDs[0] })
+	if sess != c2.sess || id != "anon-2" || c2.sess.seatIn(room.ID) != 0 {
+		t.Fatalf("seat 0 held by %s (%p), want anon-2 (%p)", id, sess, c2.sess)
+	}
+}
@@ -0,0 +1,107 @@
+package ws
+
+import "strings"
+
+// Card play rules shared by the hub and the bots: follow suit if you can,
+// otherwise trump if you can. The Weli always counts as trump and ranks
+// right below the trump ace.
+
+var suits = []string{"hearts", "spades", "clubs", "diamonds"}
+
+var rankValue = map[string]int{
+	"ace":   14,
+	"king":  12,
+	"ober":  11,
+	"unter": 10,
+	"ten":   9,
+	"nine":  8,
+	"eight": 7,
+	"seven": 6,
+}
+
+const weliValue = 13
+
+func cardValue(c Card) int {
+	if isWeli(c) {
+		return weliValue
+	}
+	return rankValue[strings.ToLower(c.Rank)]
+}
+
+// effSuit is the suit a card counts as: the Weli always follows trump.
+func effSuit(c Card, trump string) string {
+	if isWeli(c) && trump != "" {
+		return trump
+	}
+	return strings.ToLower(c.Suit)
+}
+
+func isTrump(c Card, trump string) bool {
+	return trump != "" && effSuit(c, trump) == trump
+}
+
+func sameCard(a, b Card) bool {
+	return strings.EqualFold(a.Suit, b.Suit) && strings.EqualFold(a.Rank, b.Rank)
+}
+
+// legalPlays returns the cards of hand that may be played to a trick whose
+// lead suit is lead ("" when leading).
+func legalPlays(hand []Card, lead, trump string) []Card {
+	if lead == "" {
+		return hand
+	}
+	var follow, trumps []Card
+	for _, c := range hand {
+		if effSuit(c, trump) == lead {
+			follow = append(follow, c)
+		}
+		if isTrump(c, trump) {
+			trumps = append(trumps, c)
+		}
+	}
+	if len(follow) > 0 {
+		return follow
+	}
+	if len(trumps) > 0 {
+		return trumps
+	}
+	return hand
+}
+
+func isLegalPlay(hand []Card, c Card, lead, trump string) bool {
+	for _, l := range legalPlays(hand, lead, trump) {
+		if sameCard(l, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// beats reports whether card a beats card b given the trick's lead suit.
+func beats(a, b Card, lead, trump string) bool {
+	at, bt := isTrump(a, trump), isTrump(b, trump)
+	if at != bt {
+		return at
+	}
+	as, bs := effSuit(a, trump), effSuit(b, trump)
+	if !at && as != bs {
+		// neither trumps: only the lead suit can win
+		return as == lead && bs != lead
+	}
+	return cardValue(a) > cardValue(b)
+}
+
+// trickWinner returns the seat that takes a complete trick.
+func trickWinner(trick []Card, by []int, trump string) int {
+	if len(trick) == 0 {
+		return -1
+	}
+	lead := effSuit(trick[0], trump)
+	best := 0
+	for i := 1; i < len(trick); i++ {
+		if beats(trick[i], trick[best], lead, trump) {
+			best = i
+		}
+	}
+	return by[best]
+}
@@ -0,0 +1,65 @@
+package ws
+
+// Match scoring: each seat keeps a running score over the match, starting
+// at 0 and counting down, so lower is better. Every finished hand adds
+// handDelta to it; the time banks charge their penalty to it and a forfeit
+// books a lost hand. A score belongs to the player in the seat: it moves
+// with them when seats are swapped and is cleared when they vacate it.
+
+// handDelta is a seat's score change for a finished hand. Scores count
+// down, so lower is better: every trick taken is -1, a defender without a
+// trick gets +5 and a declarer short of the bid gets twice the bid. Hearts
+// and a knock each double the result. Seats that stayed home score 0.
+func handDelta(seat, declarer, bid, tricks int, stayed bool, trump string, knocked bool) int {
+	if stayed {
+		return 0
+	}
+	d := -tricks
+	switch {
+	case seat == declarer && tricks < bid:
+		d = 2 * bid
+	case seat != declarer && tricks == 0:
+		d = 5
+	}
+	if trump == "hearts" {
+		d *= 2
+	}
+	if knocked {
+		d *= 2
+	}
+	return d
+}
+
+// forfeitHand ends the hand at once: seat scores as a declarer who missed
+// the contract, everyone else scores nothing. Runs on the room's goroutine.
+func (h *Hub) forfeitHand(r *Room, seat int, why string) {
+	logAction(r, seat, "forfeit", why)
+	bid := max(r.BestBid, 1)
+	r.Scores[seat] += handDelta(seat, seat, bid, 0, false, r.Trump, r.RoundDouble)
+	h.endHand(r, "forfeit")
+}
+
+// scoreHand books every seat's result for a hand played to the end.
+// Runs on the room's goroutine.
+func (h *Hub) scoreHand(r *Room) {
+	for s := 0; s < r.Seats; s++ {
+		if r.PlayerIDs[s] == "" {
+			continue
+		}
+		r.Scores[s] += handDelta(s, r.BestBy, r.BestBid, r.Tricks[s], r.Stayed[s], r.Trump, r.RoundDouble)
+	}
+	h.endHand(r, "played")
+}
+
+// endHand closes the hand; how says whether it was played out or
+// forfeited.
+func (h *Hub) endHand(r *Room, how string) {
+	h.metrics.hands.Inc(how)
+	r.HandOver = true
+	r.Started = false
+	r.Phase = ""
+	// booked before anyone leaves, so the result names every player and
+	// their score as the hand left it
+	r.result = handResult(r)
+	releaseLeavers(r)
+}
@@ -0,0 +1,216 @@
+package ws
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// A Session is one player as the hub knows them: the identity and the seat
+// they hold. It outlives any one connection; a player with two tabs, or
+// who reconnects with the same token, has one session with several (or
+// for a while no) connections, and every one of them gets the table state.
+type Session struct {
+	id string
+
+	// Server-side players: bot decides, stop ends its loop
+	bot     Bot
+	botKind string
+	stop    chan struct{}
+
+	// mu guards the identity, the connections and the table memberships:
+	// roomID -> seat for tables the player sits at, and the tables they
+	// only watch
+	mu       sync.Mutex
+	name     string // from the token; "" if the player picks their own
+	authed   bool   // id and name come from a verified token
+	guest    bool   // the token is a guest token, so the name may change
+	conns    map[*Client]struct{}
+	seats    map[string]int
+	watching map[string]bool
+}
+
+func newSession(id string) *Session {
+	return &Session{
+		id:       id,
+		seats:    make(map[string]int),
+		watching: make(map[string]bool),
+		conns:    make(map[*Client]struct{}),
+	}
+}
+
+// identity returns the name from s's token and whether it has one.
+func (s *Session) identity() (name string, authed, guest bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name, s.authed, s.guest
+}
+
+// signedIn reports whether s's id comes from a verified token.
+func (s *Session) signedIn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authed
+}
+
+// setIdentity records who s is after a token was verified or issued.
+func (s *Session) setIdentity(name string, authed, guest bool) {
+	s.mu.Lock()
+	s.name, s.authed, s.guest = name, authed, guest
+	s.mu.Unlock()
+}
+
+// seatIn returns s's seat at roomID, -1 if it has none there.
+func (s *Session) seatIn(roomID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seat, ok := s.seats[roomID]; ok {
+		return seat
+	}
+	return -1
+}
+
+// member reports whether s sits at or watches roomID.
+func (s *Session) member(roomID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, seated := s.seats[roomID]
+	return seated || s.watching[roomID]
+}
+
+// sit records s at seat of roomID, which it no longer just watches.
+func (s *Session) sit(roomID string, seat int) {
+	s.mu.Lock()
+	s.seats[roomID] = seat
+	delete(s.watching, roomID)
+	s.mu.Unlock()
+}
+
+func (s *Session) unseat(roomID string) {
+	s.mu.Lock()
+	delete(s.seats, roomID)
+	s.mu.Unlock()
+}
+
+func (s *Session) watch(roomID string) {
+	s.mu.Lock()
+	s.watching[roomID] = true
+	s.mu.Unlock()
+}
+
+func (s *Session) unwatch(roomID string) {
+	s.mu.Lock()
+	delete(s.watching, roomID)
+	s.mu.Unlock()
+}
+
+// tables returns the rooms s sits at, by seat, and those it watches, as -1.
+func (s *Session) tables() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.seats)+len(s.watching))
+	for id := range s.watching {
+		out[id] = -1
+	}
+	for id, seat := range s.seats {
+		out[id] = seat
+	}
+	return out
+}
+
+// forgetRoom drops every session's membership of a room being deleted.
+// Runs on r's goroutine.
+func forgetRoom(r *Room) {
+	for _, s := range r.Sessions {
+		if s != nil {
+			s.unseat(r.ID)
+		}
+	}
+	for s := range r.watchers {
+		s.unwatch(r.ID)
+	}
+}
+
+func (s *Session) attach(c *Client) {
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	c.sess = s
+}
+
+// detach removes c and reports how many connections are left.
+func (s *Session) detach(c *Client) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+	return len(s.conns)
+}
+
+// deliver queues b, a message of type t, on every connection, dropping it
+// for any that is full.
+func (s *Session) deliver(t string, b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.push(t, b)
+	}
+}
+
+// sessionFor returns the live session for id, or registers a new one.
+// Anonymous connections pass "" and always get a fresh session.
+func (h *Hub) sessionFor(id string) *Session {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+	if id == "" {
+		id = newGuestID()
+	} else if s := h.sessions[id]; s != nil {
+		return s
+	}
+	s := newSession(id)
+	h.sessions[id] = s
+	return s
+}
+
+// dropSession forgets s once its last connection has gone.
+func (h *Hub) dropSession(s *Session) {
+	h.sessionsMu.Lock()
+	if h.sessions[s.id] == s {
+		delete(h.sessions, s.id)
+	}
+	h.sessionsMu.Unlock()
+}
+
+// sendSession sends one message to all of s's connections.
+func (h *Hub) sendSession(s *Session, t string, m any) {
+	b, _ := json.Marshal(map[string]any{"t": t, "m": m})
+	s.deliver(t, b)
+}
+
+// catchUp sends a newly attached connection every table its session sits
+// at or watches.
+func (h *Hub) catchUp(c *Client) {
+	for id, seat := range c.sess.tables() {
+		room := h.room(id)
+		if room == nil {
+			continue
+		}
+		room.do(func() { c.push("state", h.stateMsg(room, seat)) })
+	}
+}
+
+// reclaimSeats hands a signed-in player back every seat held for them by a
+// stand-in bot or since a restart, so a reconnect needs no rejoin.
+func (h *Hub) reclaimSeats(s *Session) {
+	for _, room := range h.allRooms() {
+		if !slices.Contains(room.summary.Load().players, s.id) {
+			continue
+		}
+		room.do(func() {
+			for seat, pid := range room.PlayerIDs {
+				if pid == s.id && s.seatIn(room.ID) < 0 && (isBotSeat(room, seat) || reserved(room, seat)) {
+					h.takeBack(s, room, seat)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// Shutdown takes the hub down without losing games: new tables are
+// refused, every client gets a "maintenance" notice with a countdown,
+// running hands get until the notice runs out, then every room is frozen
+// and snapshotted and each socket is closed with 1001 Going Away. It gives
+// up waiting when ctx ends.
+func (h *Hub) Shutdown(ctx context.Context, notice time.Duration) error {
+	h.draining.Store(true)
+	deadline := time.Now().Add(notice)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	h.broadcastAll("maintenance", map[string]any{
+		"draining": true,
+		"msg":      "The server is restarting. Your game is saved; reconnect in a moment to carry on.",
+		"deadline": deadline.UnixMilli(),
+		"seconds":  int(time.Until(deadline).Round(time.Second).Seconds()),
+	})
+	select {
+	case <-time.After(time.Until(deadline)):
+	case <-ctx.Done():
+	}
+
+	// from here on nothing moves: no actions, bots, timers or cleanup
+	h.stopped.Store(true)
+	err := h.SnapshotRooms()
+	for _, r := range h.allRooms() {
+		r.do(func() {
+			stopBots(r)
+			stopTurnTimer(r)
+			stopClock(r)
+		})
+	}
+
+	h.clientsMu.RLock()
+	conns := make([]*websocket.Conn, 0, len(h.clients))
+	for c := range h.clients {
+		if c.conn != nil {
+			conns = append(conns, c.conn)
+		}
+	}
+	h.clientsMu.RUnlock()
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.Close(websocket.StatusGoingAway, "server restarting")
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("shutdown: gave up closing %d sockets", len(conns))
+	}
+	return err
+}
+
+// broadcastAll sends one message to every connected client.
+func (h *Hub) broadcastAll(t string, m any) {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	for c := range h.clients {
+		h.send(c, t, m)
+	}
+}
@@ -0,0 +1,232 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// Headless simulation: whole matches played in-process by bots on every
+// seat, driven synchronously through the same handlers as live tables.
+
+// SimConfig describes a batch of simulated matches.
+type SimConfig struct {
+	Seats     int
+	Matches   int
+	Target    int      // a match ends once a seat's score reaches -Target
+	MaxHands  int      // per match; stops matches that never end
+	Bots      []string // bot kind per seat, repeated if shorter than Seats
+	KnockRate float64  // chance the first bidder knocks instead of cutting
+	Seed      int64
+	Rules     RoomRules // table options; timers and time banks are ignored
+}
+
+// SimStats summarises a Simulate run. Bid arrays are indexed by bid (1..5).
+type SimStats struct {
+	Matches    int      `json:"matches"`
+	Hands      int      `json:"hands"`
+	Crashes    int      `json:"crashes"`    // hands that panicked
+	Stuck      int      `json:"stuck"`      // hands where no valid move came
+	Violations int      `json:"violations"` // failed invariant checks
+	Problems   []string `json:"problems"`   // the first few crashes and stuck states
+
+	Bids     [6]int `json:"bids"`     // hands won at each bid
+	BidsMade [6]int `json:"bidsMade"` // of those, hands the declarer made
+
+	Knocked      int `json:"knocked"`
+	KnockedMade  int `json:"knockedMade"`
+	KnockedSwing int `json:"knockedSwing"` // sum of |score change| over seats
+	PlainMade    int `json:"plainMade"`
+	PlainSwing   int `json:"plainSwing"`
+
+	// HandWins counts the best result of each hand by position after the
+	// dealer (0 = first bidder); ties count for every seat involved.
+	HandWins  []int `json:"handWins"`
+	MatchWins []int `json:"matchWins"` // by seat
+	Unended   int   `json:"unended"`   // matches cut off at MaxHands
+
+	Actions    int `json:"actions"` // decisions taken, all hands
+	Tricks     int `json:"tricks"`
+	StayedHome int `json:"stayedHome"`
+	MatchHands int `json:"matchHands"` // hands played in finished matches
+}
+
+const maxSimProblems = 20
+
+func (cfg SimConfig) withDefaults() SimConfig {
+	if cfg.Seats < 2 {
+		cfg.Seats = 3
+	}
+	if cfg.Target <= 0 {
+		cfg.Target = 21
+	}
+	if cfg.MaxHands <= 0 {
+		cfg.MaxHands = 200
+	}
+	if len(cfg.Bots) == 0 {
+		cfg.Bots = []string{"heuristic"}
+	}
+	cfg.Rules.TurnSeconds = nil
+	cfg.Rules.TimeBank = 0
+	return cfg
+}
+
+// Simulate plays cfg.Matches matches. The same config and seed always
+// produce the same games.
+func Simulate(cfg SimConfig) SimStats {
+	cfg = cfg.withDefaults()
+	st := SimStats{HandWins: make([]int, cfg.Seats), MatchWins: make([]int, cfg.Seats)}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	for i := 0; i < cfg.Matches; i++ {
+		simMatch(cfg, rng.Int63(), &st)
+		st.Matches++
+	}
+	return st
+}
+
+func (st *SimStats) problem(format string, args ...any) {
+	if len(st.Problems) < maxSimProblems {
+		st.Problems = append(st.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func simMatch(cfg SimConfig, seed int64, st *SimStats) {
+	rng := rand.New(rand.NewSource(seed))
+	h := NewHub(nil)
+	h.botDelay = 0
+	h.SetDebug(true)
+	h.onViolation = func(msg string) {
+		st.Violations++
+		st.problem("match seed %d: %s", seed, msg)
+	}
+	room := newRoom("sim", cfg.Seats, "", rng.Int63())
+	room.Rules = cfg.Rules
+	bots := make([]Bot, cfg.Seats)
+	for s := range bots {
+		bots[s] = newSeededBot(cfg.Bots[s%len(cfg.Bots)], rng.Int63())
+	}
+	players := seatHeadless(h, room, "sim")
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+
+	for hand := 1; hand <= cfg.MaxHands; hand++ {
+		if err := simHand(h, room, bots, players, cfg, rng, st); err != "" {
+			st.problem("match seed %d, hand %d: %s", seed, hand, err)
+			return
+		}
+		best := 0
+		for s := 1; s < cfg.Seats; s++ {
+			if room.Scores[s] < room.Scores[best] {
+				best = s
+			}
+		}
+		if room.Scores[best] <= -cfg.Target {
+			st.MatchWins[best]++
+			st.MatchHands += hand
+			return
+		}
+	}
+	st.Unended++
+}
+
+// seatHeadless seats a player named prefix-<seat> at every seat of room and
+// returns a connection per seat to act through. Their sessions have no
+// live connections, so states sent to them go nowhere, and errors fill the
+// one-slot send queue and are then dropped. Call before addRoom.
+func seatHeadless(h *Hub, room *Room, prefix string) []*Client {
+	clients := make([]*Client, room.Seats)
+	for seat := range clients {
+		s := newSession(fmt.Sprintf("%s-%d", prefix, seat))
+		s.sit(room.ID, seat)
+		room.PlayerIDs[seat] = s.id
+		room.Sessions[seat] = s
+		clients[seat] = &Client{hub: h, send: make(chan []byte, 1), sess: s}
+	}
+	return clients
+}
+
+// simHand deals and plays one hand to the end and books its statistics.
+// It returns a description if the hand crashed or got stuck.
+func simHand(h *Hub, room *Room, bots []Bot, players []*Client, cfg SimConfig, rng *rand.Rand, st *SimStats) (problem string) {
+	defer func() {
+		if r := recover(); r != nil {
+			st.Crashes++
+			problem = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	before := make([]int, cfg.Seats)
+	for s := range before {
+		before[s] = room.Scores[s]
+	}
+
+	room.do(func() { h.startHand(room) })
+	for steps := 0; room.Phase != ""; steps++ {
+		seat := actingSeat(room)
+		if seat < 0 || steps > 500 {
+			st.Stuck++
+			return fmt.Sprintf("nobody to act in %q", room.Phase)
+		}
+		var a botAction
+		if room.Phase == "start" && rng.Float64() < cfg.KnockRate {
+			a = botAction{T: "start_choice", M: map[string]any{"choice": "knock"}}
+		} else {
+			var v *seatView
+			room.do(func() { v = parseState(h.stateMsg(room, seat)) })
+			var ok bool
+			if a, ok = bots[seat].Act(v); !ok {
+				st.Stuck++
+				return fmt.Sprintf("seat %d has no move in %q", seat, room.Phase)
+			}
+		}
+		key := turnKey(room, seat)
+		a.M["room"] = room.ID
+		a.M["seat"] = seat
+		data, _ := json.Marshal(map[string]any{"t": a.T, "m": a.M})
+		h.handleRaw(players[seat], data)
+		st.Actions++
+		if room.Phase != "" && actingSeat(room) == seat && turnKey(room, seat) == key {
+			st.Stuck++
+			return fmt.Sprintf("seat %d: %s %v rejected in %q", seat, a.T, a.M, room.Phase)
+		}
+	}
+
+	st.Hands++
+	decl, bid := room.BestBy, room.BestBid
+	made := decl >= 0 && room.Tricks[decl] >= bid
+	if bid >= 1 && bid <= 5 {
+		st.Bids[bid]++
+		if made {
+			st.BidsMade[bid]++
+		}
+	}
+	swing := 0
+	deltas := make([]int, cfg.Seats)
+	for s := range deltas {
+		deltas[s] = room.Scores[s] - before[s]
+		swing += max(deltas[s], -deltas[s])
+		st.Tricks += room.Tricks[s]
+		if room.Stayed[s] {
+			st.StayedHome++
+		}
+	}
+	best := slices.Min(deltas)
+	for s, d := range deltas {
+		if d == best {
+			st.HandWins[(s-room.FirstBidder+cfg.Seats)%cfg.Seats]++
+		}
+	}
+	if room.RoundDouble {
+		st.Knocked++
+		st.KnockedSwing += swing
+		if made {
+			st.KnockedMade++
+		}
+	} else {
+		st.PlainSwing += swing
+		if made {
+			st.PlainMade++
+		}
+	}
+	return ""
+}
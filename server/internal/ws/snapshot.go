@@ -0,0 +1,328 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/youngZwiebelandtheGemuseBeat/reusable_online_card_game_framework/server/internal/store"
+)
+
+// Snapshots: every room is written to the store periodically and on
+// shutdown, and restored on boot. People's seats come back empty but
+// reserved, so their players can rejoin with their seat token or account;
+// bots come back as they were. Timers restart from scratch and time banks
+// from what was left.
+
+// snapshotVersion is bumped whenever roomSnapshot changes shape; restore
+// migrates older versions up and refuses newer ones.
+const snapshotVersion = 1
+
+type roomSnapshot struct {
+	Version int `json:"v"`
+
+	ID        string    `json:"id"`
+	Game      string    `json:"game"`
+	Seats     int       `json:"seats"`
+	Rules     RoomRules `json:"rules"`
+	Host      string    `json:"host"`
+	Locked    bool      `json:"locked"`
+	Banned    []string  `json:"banned,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Private       bool      `json:"private"`
+	InviteCode    string    `json:"inviteCode,omitempty"`
+	InviteExpires time.Time `json:"inviteExpires"`
+	PassHash      []byte    `json:"passHash,omitempty"`
+
+	// Seats: who sits where, their names and seat tokens, which seats
+	// are bots (seat -> kind) and which bots finish a hand for a leaver
+	PlayerIDs  []string       `json:"playerIds"`
+	Names      []string       `json:"names"`
+	SeatTokens map[int]string `json:"seatTokens"`
+	Bots       map[int]string `json:"bots,omitempty"`
+	Leavers    map[int]bool   `json:"leavers,omitempty"`
+	Ready      map[int]bool   `json:"ready,omitempty"`
+	SwapReq    map[int]int    `json:"swapReq,omitempty"`
+
+	Hands       map[int][]Card `json:"hands"`
+	Lead        string         `json:"lead"`
+	Trick       []Card         `json:"trick"`
+	TrickBy     []int          `json:"trickBy"`
+	Turn        int            `json:"turn"`
+	HandOver    bool           `json:"handOver"`
+	Tricks      map[int]int    `json:"tricks"`
+	LastTrick   []Card         `json:"lastTrick"`
+	LastTrickBy []int          `json:"lastTrickBy"`
+	LastWinner  int            `json:"lastWinner"`
+	Trump       string         `json:"trump"`
+	Started     bool           `json:"started"`
+	Dealer      int            `json:"dealer"`
+	FirstBidder int            `json:"firstBidder"`
+	Phase       string         `json:"phase"`
+	Actor       int            `json:"actor"`
+	BestBid     int            `json:"bestBid"`
+	BestBy      int            `json:"bestBy"`
+	Passed      map[int]bool   `json:"passed"`
+	RoundDouble bool           `json:"roundDouble"`
+	Stayed      map[int]bool   `json:"stayed"`
+	Acted       map[int]bool   `json:"acted"`
+	CutPeek     Card           `json:"cutPeek"`
+	HasCutPeek  bool           `json:"hasCutPeek"`
+	WeliKeptBy  int            `json:"weliKeptBy"`
+
+	Stock          []Card `json:"stock"`
+	Swamp          []Card `json:"swamp"`
+	SwampShuffled  bool   `json:"swampShuffled"`
+	Taken          []Card `json:"taken"`
+	Out            []Card `json:"out"`
+	ExchangeMax    int    `json:"exchangeMax"`
+	ExchangeClosed bool   `json:"exchangeClosed"`
+
+	Clocks  map[int]time.Duration `json:"clocks"` // left per seat
+	Flagged map[int]bool          `json:"flagged,omitempty"`
+	Scores  map[int]int           `json:"scores"`
+	History []HistoryEntry        `json:"history"`
+	Logged  int                   `json:"logged"`
+	Result  *store.Result         `json:"result,omitempty"` // not stored yet
+}
+
+// snapshotRoom captures r. Runs on the room's goroutine.
+func (h *Hub) snapshotRoom(r *Room) roomSnapshot {
+	s := roomSnapshot{
+		Version: snapshotVersion,
+
+		ID: r.ID, Game: r.Game, Seats: r.Seats, Rules: r.Rules, Host: r.Host,
+		Locked: r.Locked, CreatedAt: r.CreatedAt,
+
+		Private: r.Private, InviteCode: r.InviteCode, InviteExpires: r.InviteExpires, PassHash: r.passHash,
+
+		PlayerIDs:  r.PlayerIDs,
+		Names:      make([]string, r.Seats),
+		SeatTokens: r.seatTokens,
+		Bots:       make(map[int]string),
+		Leavers:    r.leavers,
+		Ready:      r.Ready,
+		SwapReq:    r.swapReq,
+
+		Hands: r.Hands, Lead: r.Lead, Trick: r.Trick, TrickBy: r.TrickBy, Turn: r.Turn,
+		HandOver: r.HandOver, Tricks: r.Tricks, LastTrick: r.LastTrick,
+		LastTrickBy: r.LastTrickBy, LastWinner: r.LastWinner, Trump: r.Trump,
+		Started: r.Started, Dealer: r.Dealer, FirstBidder: r.FirstBidder,
+		Phase: r.Phase, Actor: r.Actor, BestBid: r.BestBid, BestBy: r.BestBy,
+		Passed: r.Passed, RoundDouble: r.RoundDouble, Stayed: r.Stayed, Acted: r.Acted,
+		CutPeek: r.CutPeek, HasCutPeek: r.HasCutPeek, WeliKeptBy: r.WeliKeptBy,
+
+		Stock: r.stock, Swamp: r.swamp, SwampShuffled: r.swampShuffled,
+		Taken: r.taken, Out: r.out, ExchangeMax: r.exchangeMax, ExchangeClosed: r.exchangeClosed,
+
+		Clocks:  make(map[int]time.Duration, len(r.Clocks)),
+		Flagged: r.flagged,
+		Scores:  r.Scores,
+		History: r.History,
+		Logged:  r.logged,
+		Result:  r.result,
+	}
+	for b := range r.banned {
+		s.Banned = append(s.Banned, b)
+	}
+	h.namesMu.RLock()
+	for seat, id := range r.PlayerIDs {
+		s.Names[seat] = h.names[id]
+	}
+	h.namesMu.RUnlock()
+	for seat, sess := range r.Sessions {
+		// a stand-in plays under its player's id; only real bots are kept
+		if sess != nil && sess.bot != nil && (sess.id == r.PlayerIDs[seat] || r.leavers[seat]) {
+			s.Bots[seat] = sess.botKind
+		}
+	}
+	for seat := range r.Clocks {
+		s.Clocks[seat] = clockLeft(r, seat)
+	}
+	return s
+}
+
+// restoreRoom rebuilds a room from a snapshot, bringing older versions up
+// to date first.
+func (h *Hub) restoreRoom(s roomSnapshot) (*Room, error) {
+	switch {
+	case s.Version > snapshotVersion:
+		return nil, fmt.Errorf("snapshot version %d is newer than %d", s.Version, snapshotVersion)
+	case s.Version < 1:
+		return nil, fmt.Errorf("snapshot version %d is unknown", s.Version)
+	}
+	if s.Seats < 2 || len(s.PlayerIDs) != s.Seats {
+		return nil, fmt.Errorf("snapshot has %d seats and %d players", s.Seats, len(s.PlayerIDs))
+	}
+
+	// the deck's random source can't be saved; a restored room gets a new one
+	r := newRoom(s.ID, s.Seats, s.Host, time.Now().UnixNano())
+	r.Game, r.Rules, r.Locked, r.CreatedAt = s.Game, s.Rules, s.Locked, s.CreatedAt
+	r.Private, r.InviteCode, r.InviteExpires, r.passHash = s.Private, s.InviteCode, s.InviteExpires, s.PassHash
+	for _, b := range s.Banned {
+		r.banned[b] = true
+	}
+	r.PlayerIDs = s.PlayerIDs
+
+	r.Lead, r.Trick, r.TrickBy, r.Turn = s.Lead, s.Trick, s.TrickBy, s.Turn
+	r.HandOver, r.LastTrick, r.LastTrickBy, r.LastWinner = s.HandOver, s.LastTrick, s.LastTrickBy, s.LastWinner
+	r.Trump, r.Started, r.Dealer, r.FirstBidder = s.Trump, s.Started, s.Dealer, s.FirstBidder
+	r.Phase, r.Actor, r.BestBid, r.BestBy = s.Phase, s.Actor, s.BestBid, s.BestBy
+	r.RoundDouble, r.CutPeek, r.HasCutPeek, r.WeliKeptBy = s.RoundDouble, s.CutPeek, s.HasCutPeek, s.WeliKeptBy
+	r.stock, r.swamp, r.swampShuffled = s.Stock, s.Swamp, s.SwampShuffled
+	r.taken, r.out, r.exchangeMax, r.exchangeClosed = s.Taken, s.Out, s.ExchangeMax, s.ExchangeClosed
+	r.History, r.logged, r.result = s.History, s.Logged, s.Result
+
+	// nil maps in the snapshot keep newRoom's empty ones
+	fill(&r.seatTokens, s.SeatTokens)
+	fill(&r.leavers, s.Leavers)
+	fill(&r.Ready, s.Ready)
+	fill(&r.swapReq, s.SwapReq)
+	fill(&r.Hands, s.Hands)
+	fill(&r.Passed, s.Passed)
+	fill(&r.Stayed, s.Stayed)
+	fill(&r.Acted, s.Acted)
+	fill(&r.Clocks, s.Clocks)
+	fill(&r.flagged, s.Flagged)
+	fill(&r.Scores, s.Scores)
+	r.Tricks = make(map[int]int, s.Seats)
+	fill(&r.Tricks, s.Tricks)
+
+	h.namesMu.Lock()
+	for seat, name := range s.Names {
+		if id := r.PlayerIDs[seat]; id != "" && name != "" {
+			h.names[id] = name
+			h.namesSeen[id] = time.Now()
+		}
+	}
+	h.namesMu.Unlock()
+	for seat, kind := range s.Bots {
+		if seat < 0 || seat >= r.Seats {
+			continue
+		}
+		bs := h.newBotSession(kind)
+		if !r.leavers[seat] {
+			bs.id = r.PlayerIDs[seat]
+		}
+		bs.sit(r.ID, seat)
+		r.Sessions[seat] = bs
+	}
+	r.lastActive = time.Now() // give players time to come back
+	// the turn timer starts over; the bank to act runs from what it had left
+	h.armTurnTimer(r)
+	h.runClock(r)
+	return r, nil
+}
+
+func fill[K comparable, V any](dst *map[K]V, src map[K]V) {
+	if src != nil {
+		*dst = src
+	}
+}
+
+// reserved reports whether seat belongs to a player who has not come back
+// since a restart.
+func reserved(room *Room, seat int) bool {
+	return room.PlayerIDs[seat] != "" && room.Sessions[seat] == nil
+}
+
+// SnapshotRooms writes every room to the store.
+func (h *Hub) SnapshotRooms() error {
+	return h.snapshotRooms(true)
+}
+
+// snapshotRooms writes the rooms that changed since their last snapshot,
+// or all of them.
+func (h *Hub) snapshotRooms(all bool) error {
+	type pending struct {
+		id   string
+		data []byte
+	}
+	var todo []pending
+	var errs error
+	for _, r := range h.allRooms() {
+		r.do(func() {
+			if !all && !r.snapshotAt.IsZero() && !r.lastActive.After(r.snapshotAt) {
+				return
+			}
+			b, err := json.Marshal(h.snapshotRoom(r))
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("snapshot %s: %w", r.ID, err))
+				return
+			}
+			r.snapshotAt = time.Now()
+			todo = append(todo, pending{r.ID, b})
+		})
+	}
+	for _, p := range todo {
+		if err := h.store.SaveRoom(store.Room{ID: p.id, Data: p.data, Saved: time.Now()}); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("snapshot %s: %w", p.id, err))
+		}
+	}
+	return errs
+}
+
+// RestoreRooms loads the rooms saved by SnapshotRooms. Call before serving.
+func (h *Hub) RestoreRooms() (int, error) {
+	saved, err := h.store.Rooms()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, sr := range saved {
+		var s roomSnapshot
+		if err := json.Unmarshal(sr.Data, &s); err != nil {
+			log.Printf("restore %s: %v", sr.ID, err)
+			continue
+		}
+		r, err := h.restoreRoom(s)
+		if err != nil {
+			log.Printf("restore %s: %v", sr.ID, err)
+			continue
+		}
+		r.snapshotAt = r.lastActive
+		h.addRoom(r)
+		// the restored bots wait for a state to act on
+		r.do(func() {
+			for _, s := range r.Sessions {
+				if s != nil {
+					h.sendStateTo(s, r)
+				}
+			}
+		})
+		n++
+	}
+	return n, nil
+}
+
+// StartSnapshots snapshots changed rooms every interval until ctx is done.
+func (h *Hub) StartSnapshots(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := h.snapshotRooms(false); err != nil {
+					log.Printf("snapshot: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// dropSnapshot forgets a deleted room so it isn't restored.
+func (h *Hub) dropSnapshot(id string) {
+	if err := h.store.DeleteRoom(id); err != nil {
+		log.Printf("store: delete room %s: %v", id, err)
+	}
+}
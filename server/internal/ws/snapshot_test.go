@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSnapshotRoundTrip saves a room in the middle of the play, after an
+// exchange, with a bot in one seat and time banks running, and restores it
+// on a fresh hub: the game comes back as it was, people's seats are
+// reserved for them, the bot is back and the timers run again.
+func TestSnapshotRoundTrip(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour // the bot never gets to move on its own
+	room := newRoom("snap", 3, "p-0", 7)
+	room.Rules.TimeBank = 120
+	room.Rules.Increment = 5
+	room.Rules.TurnSeconds = map[string]int{"bidding": 60, "exchange": 60, "play": 60}
+	players := seatHeadless(h, room, "p")
+	bot := h.newBotSession("heuristic")
+	bot.sit(room.ID, 2)
+	room.PlayerIDs[2], room.Sessions[2] = bot.id, bot
+	players[2] = &Client{hub: h, send: make(chan []byte, 64), sess: bot}
+	room.seatTokens[0] = "token-0"
+	room.Scores[1] = -4
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+
+	// play until the first card of the first trick is down: the first
+	// bidder bids, the rest pass, and everyone in exchanges some cards
+	room.do(func() { h.startHand(room) })
+	for step := 0; ; step++ {
+		var seat int
+		var a botAction
+		done := false
+		room.do(func() {
+			if done = room.Phase == "play" && len(room.Trick) == 1; done {
+				return
+			}
+			seat = actingSeat(room)
+			acts := legalActions(room, seat, func() int { return 1 })
+			a = acts[0]
+			for _, x := range acts {
+				switch {
+				case room.Phase == "bidding" && room.BestBy == -1 && x.T == "bid",
+					room.Phase == "exchange" && x.T == "exchange":
+					a = x
+				}
+			}
+		})
+		if done {
+			break
+		}
+		if step > 100 || seat < 0 {
+			t.Fatalf("stuck in %q at step %d", room.Phase, step)
+		}
+		a.M["room"] = room.ID
+		data, _ := json.Marshal(map[string]any{"t": a.T, "m": a.M})
+		h.handleRaw(players[seat], data)
+	}
+
+	// t.Fatal would end the room's goroutine, so checks on it only report
+	var saved roomSnapshot
+	var data []byte
+	var err error
+	room.do(func() {
+		saved = h.snapshotRoom(room)
+		data, err = json.Marshal(saved)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Stock) == 0 || len(saved.Swamp) == 0 || saved.ExchangeMax == 0 {
+		t.Fatalf("no exchange took place: stock %d, swamp %d", len(saved.Stock), len(saved.Swamp))
+	}
+
+	h2 := NewHub(nil)
+	h2.botDelay = time.Hour
+	var s roomSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	r, err := h2.restoreRoom(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2.addRoom(r)
+	defer r.do(func() { h2.removeRoom(r) })
+
+	r.do(func() {
+		// everything but the banks, which run on, comes back as saved
+		again := h2.snapshotRoom(r)
+		again.Clocks, saved.Clocks = nil, nil
+		a, _ := json.Marshal(again)
+		b, _ := json.Marshal(saved)
+		if string(a) != string(b) {
+			t.Errorf("restored room differs:\n got %s\nwant %s", a, b)
+		}
+		for seat, left := range s.Clocks {
+			if got := clockLeft(r, seat); got > left || got < left-time.Second {
+				t.Errorf("seat %d bank %v, saved %v", seat, got, left)
+			}
+		}
+		if !reflect.DeepEqual(r.stock, s.Stock) || !reflect.DeepEqual(r.swamp, s.Swamp) || r.exchangeMax != s.ExchangeMax {
+			t.Errorf("talon, swamp or exchange limit changed")
+		}
+		for seat := 0; seat < 2; seat++ {
+			if !reserved(r, seat) {
+				t.Errorf("seat %d not reserved for %s", seat, r.PlayerIDs[seat])
+			}
+		}
+		if r.seatTokens[0] != "token-0" {
+			t.Errorf("seat token %q", r.seatTokens[0])
+		}
+		if b := r.Sessions[2]; b == nil || b.bot == nil || b.botKind != "heuristic" || b.id != bot.id {
+			t.Errorf("bot not restored: %+v", b)
+		}
+		seat := actingSeat(r)
+		if r.turnTimer == nil || r.Deadline.IsZero() {
+			t.Error("turn timer not restarted")
+		}
+		if r.flagTimer == nil || r.clockSeat != seat {
+			t.Errorf("bank of seat %d not running (clock seat %d)", seat, r.clockSeat)
+		}
+	})
+}
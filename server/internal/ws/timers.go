@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"fmt"
+	"time"
+)
+
+// Turn timers: when Rules.TurnSeconds has a limit for the current phase,
+// the seat that has to act gets that long before the server acts for it
+// with a safe default (pass, exchange_done, lowest legal card, ...).
+
+// actingSeat returns the seat the game is waiting on, or -1.
+func actingSeat(r *Room) int {
+	switch r.Phase {
+	case "start", "cut":
+		return r.FirstBidder
+	case "bidding", "exchange":
+		return r.Actor
+	case "pick_trump":
+		return r.BestBy
+	case "play":
+		if !r.HandOver {
+			return r.Turn
+		}
+	}
+	return -1
+}
+
+// turnKey identifies one pending decision; it changes with every accepted
+// action, so rejected actions don't reset the clock.
+func turnKey(r *Room, seat int) string {
+	cards := len(r.Trick)
+	for _, h := range r.Hands {
+		cards += len(h)
+	}
+	return fmt.Sprintf("%s/%d/%d/%d/%d/%d", r.Phase, seat, cards, r.BestBid, len(r.Passed), len(r.Acted))
+}
+
+// armTurnTimer starts, keeps or cancels the room's turn timer to match the
+// current decision. Runs on the room's goroutine.
+func (h *Hub) armTurnTimer(r *Room) {
+	seat := actingSeat(r)
+	limit := time.Duration(r.Rules.TurnSeconds[r.Phase]) * time.Second
+	if seat < 0 || limit <= 0 || r.closed {
+		stopTurnTimer(r)
+		return
+	}
+	key := turnKey(r, seat)
+	if key == r.timerKey {
+		return
+	}
+	stopTurnTimer(r)
+	r.timerKey = key
+	r.Deadline = time.Now().Add(limit)
+	r.turnTimer = time.AfterFunc(limit, func() { h.turnTimeout(r, key) })
+}
+
+// stopTurnTimer cancels any pending timeout. Runs on the room's goroutine.
+func stopTurnTimer(r *Room) {
+	if r.turnTimer != nil {
+		r.turnTimer.Stop()
+		r.turnTimer = nil
+	}
+	r.timerKey = ""
+	r.Deadline = time.Time{}
+}
+
+func (h *Hub) turnTimeout(r *Room, key string) {
+	r.do(func() {
+		seat := actingSeat(r)
+		if h.stopped.Load() || r.timerKey != key || seat < 0 {
+			return
+		}
+		r.timerKey = ""
+		h.metrics.timeouts.Inc("turn")
+		logAction(r, seat, "timeout", "")
+		typ, m := defaultAction(r, seat)
+		h.broadcastRoom(r, "timeout", map[string]any{"room": r.ID, "seat": seat, "action": typ})
+		h.actFor(r, seat, typ, m)
+	})
+}
+
+// defaultAction is the safe move made for a seat that ran out of time.
+// Runs on the room's goroutine.
+func defaultAction(r *Room, seat int) (string, map[string]any) {
+	switch r.Phase {
+	case "start":
+		return "start_choice", map[string]any{"choice": "cut"}
+	case "cut":
+		return "cut_proceed", map[string]any{}
+	case "bidding":
+		if r.BestBy == -1 && countActiveBidders(r) == 1 {
+			// passing would leave nobody to play the hand
+			return "bid", map[string]any{"bid": 1}
+		}
+		return "pass", map[string]any{}
+	case "pick_trump":
+		suit, _ := bestTrump(r.Hands[seat])
+		return "pick_trump", map[string]any{"trump": suit}
+	case "exchange":
+		return "exchange_done", map[string]any{}
+	case "play":
+		legal := byStrength(legalPlays(r.Hands[seat], r.Lead, r.Trump), r.Trump)
+		if len(legal) == 0 {
+			return "move", map[string]any{}
+		}
+		c := legal[0]
+		return "move", map[string]any{"type": "play_card", "card": map[string]any{"Suit": c.Suit, "Rank": c.Rank}}
+	}
+	return "", map[string]any{}
+}
+
+func countActiveBidders(r *Room) int {
+	n := 0
+	for s := 0; s < r.Seats; s++ {
+		if r.PlayerIDs[s] != "" && !r.Passed[s] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestTurnTimeout lets a turn run out: the server plays the default action
+// for the seat and logs it. A timer cancelled, or outrun by the player's
+// own action, does nothing when it fires.
+func TestTurnTimeout(t *testing.T) {
+	h := NewHub(nil)
+	h.botDelay = time.Hour
+	room := newRoom("timeout", 3, "p-0", 7)
+	room.Rules.TurnSeconds = map[string]int{"start": 60, "cut": 60}
+	seatHeadless(h, room, "p")
+	h.addRoom(room)
+	defer room.do(func() { h.removeRoom(room) })
+
+	var key, phase string
+	var seat int
+	var log []HistoryEntry
+	state := func() {
+		room.do(func() {
+			key, phase, seat = room.timerKey, room.Phase, actingSeat(room)
+			log = slices.Clone(room.History)
+		})
+	}
+	timedOut := func() bool {
+		return slices.ContainsFunc(log, func(e HistoryEntry) bool { return e.Action == "timeout" })
+	}
+
+	room.do(func() { h.startHand(room) })
+	state()
+	if phase != "start" || key == "" {
+		t.Fatalf("phase %q, timer %q after the deal", phase, key)
+	}
+
+	// cancelled: the timer's callback comes too late
+	room.do(func() { stopTurnTimer(room) })
+	h.turnTimeout(room, key)
+	state()
+	if phase != "start" || timedOut() {
+		t.Fatalf("cancelled timer acted: phase %q, log %+v", phase, log)
+	}
+
+	room.do(func() { h.armTurnTimer(room) })
+	state()
+	starter, first := seat, key
+	h.turnTimeout(room, first)
+	state()
+	if phase != "cut" {
+		t.Fatalf("phase %q after the timeout, want cut", phase)
+	}
+	i := slices.IndexFunc(log, func(e HistoryEntry) bool { return e.Action == "timeout" })
+	if i < 0 || log[i].Seat != starter {
+		t.Fatalf("timeout of seat %d not logged: %+v", starter, log)
+	}
+	if i+1 >= len(log) || log[i+1].Action != "start_choice" || log[i+1].Seat != starter {
+		t.Fatalf("no default start choice after the timeout: %+v", log[i:])
+	}
+
+	// outrun: the player acted, so the old key no longer matches
+	n := len(log)
+	h.turnTimeout(room, first)
+	state()
+	if phase != "cut" || len(log) != n {
+		t.Fatalf("stale timer acted: phase %q, log %+v", phase, log[n:])
+	}
+}